@@ -0,0 +1,196 @@
+package klog
+
+import (
+	"fmt"
+	stdlog "log"
+	"os"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/grpclog"
+)
+
+// SplitLevel 取出日志内容开头的级别，例：[ERROR] xxx、WARN: xxx
+// 没有级别时返回 def 和原内容，panic、fatal 按 error 处理，依赖库的日志不能导致服务 panic 或退出
+func SplitLevel(msg string, def Level) (Level, string) {
+	s := strings.TrimLeft(msg, " ")
+	end := strings.IndexAny(s, " :]")
+	if end <= 0 {
+		return def, msg
+	}
+	var word string
+	switch {
+	case s[0] == '[' && s[end] == ']':
+		word = s[1:end]
+	case s[0] != '[' && s[end] == ':':
+		word = s[:end]
+	default:
+		return def, msg
+	}
+	lvl, err := logrus.ParseLevel(strings.ToLower(word))
+	if err != nil {
+		return def, msg
+	}
+	if lvl < logrus.ErrorLevel {
+		lvl = logrus.ErrorLevel
+	}
+	return lvl.String(), strings.TrimLeft(s[end+1:], " :")
+}
+
+// SaramaLevel sarama 日志的级别，内容开头有级别时使用该级别，连接失败、重试等为 warn，其余为 info
+func SaramaLevel(msg string) (Level, string) {
+	level, msg := SplitLevel(msg, "")
+	if level != "" {
+		return level, msg
+	}
+	lower := strings.ToLower(msg)
+	for _, word := range []string{"error", "failed", "unable", "retrying"} {
+		if strings.Contains(lower, word) {
+			return LevelWarn, msg
+		}
+	}
+	return LevelInfo, msg
+}
+
+// logMessage 输出适配器转换的日志，级别最高为 error
+func (l *Logger) logMessage(level Level, msg string) {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		lvl = logrus.InfoLevel
+	} else if lvl < logrus.ErrorLevel {
+		lvl = logrus.ErrorLevel
+	}
+	l.outputMessage(lvl, msg)
+}
+
+func (l *Logger) outputMessage(lvl logrus.Level, msg string) {
+	l.output(lvl, []interface{}{log.DefaultMessageKey, strings.TrimSuffix(msg, "\n")})
+}
+
+// stdWriter 将标准库 log 的输出写入 klog
+type stdWriter struct {
+	logger *Logger
+	level  Level
+}
+
+func (w *stdWriter) Write(p []byte) (int, error) {
+	level, msg := SplitLevel(string(p), w.level)
+	w.logger.logMessage(level, msg)
+	return len(p), nil
+}
+
+// StdLogger 返回写入 klog 的标准库 *log.Logger，例：http.Server 的 ErrorLog
+// 内容开头有级别时使用该级别，否则使用 level
+func (l *Logger) StdLogger(level Level) *stdlog.Logger {
+	return stdlog.New(&stdWriter{logger: l, level: level}, "", 0)
+}
+
+// RedirectStdLog 将标准库 log 包的默认输出写入 klog，返回恢复原设置的函数
+func (l *Logger) RedirectStdLog(level Level) func() {
+	flags, prefix, out := stdlog.Flags(), stdlog.Prefix(), stdlog.Writer()
+	stdlog.SetFlags(0)
+	stdlog.SetPrefix("")
+	stdlog.SetOutput(&stdWriter{logger: l, level: level})
+	return func() {
+		stdlog.SetFlags(flags)
+		stdlog.SetPrefix(prefix)
+		stdlog.SetOutput(out)
+	}
+}
+
+// saramaLogger 实现 sarama.StdLogger
+type saramaLogger struct {
+	logger *Logger
+}
+
+// NewSaramaLogger 返回写入 klog 的 sarama 日志，级别见 SaramaLevel
+// 例：sarama.Logger = klog.NewSaramaLogger(logger)
+func NewSaramaLogger(l *Logger) sarama.StdLogger {
+	return &saramaLogger{logger: l}
+}
+
+func (s *saramaLogger) Print(v ...interface{}) {
+	s.logger.logMessage(SaramaLevel(fmt.Sprint(v...)))
+}
+
+func (s *saramaLogger) Printf(format string, v ...interface{}) {
+	s.logger.logMessage(SaramaLevel(fmt.Sprintf(format, v...)))
+}
+
+func (s *saramaLogger) Println(v ...interface{}) {
+	s.logger.logMessage(SaramaLevel(fmt.Sprintln(v...)))
+}
+
+// grpcLogger 实现 grpclog.LoggerV2
+type grpcLogger struct {
+	logger    *Logger
+	verbosity int
+}
+
+// NewGRPCLogger 返回写入 klog 的 grpc 日志，verbosity 为 grpc 的详细程度（GRPC_GO_LOG_VERBOSITY_LEVEL）
+// 例：grpclog.SetLoggerV2(klog.NewGRPCLogger(logger, 0))
+func NewGRPCLogger(l *Logger, verbosity int) grpclog.LoggerV2 {
+	return &grpcLogger{logger: l, verbosity: verbosity}
+}
+
+func (g *grpcLogger) Info(args ...interface{}) {
+	g.logger.logMessage(LevelInfo, fmt.Sprint(args...))
+}
+
+func (g *grpcLogger) Infoln(args ...interface{}) {
+	g.logger.logMessage(LevelInfo, fmt.Sprintln(args...))
+}
+
+func (g *grpcLogger) Infof(format string, args ...interface{}) {
+	g.logger.logMessage(LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (g *grpcLogger) Warning(args ...interface{}) {
+	g.logger.logMessage(LevelWarn, fmt.Sprint(args...))
+}
+
+func (g *grpcLogger) Warningln(args ...interface{}) {
+	g.logger.logMessage(LevelWarn, fmt.Sprintln(args...))
+}
+
+func (g *grpcLogger) Warningf(format string, args ...interface{}) {
+	g.logger.logMessage(LevelWarn, fmt.Sprintf(format, args...))
+}
+
+func (g *grpcLogger) Error(args ...interface{}) {
+	g.logger.logMessage(LevelError, fmt.Sprint(args...))
+}
+
+func (g *grpcLogger) Errorln(args ...interface{}) {
+	g.logger.logMessage(LevelError, fmt.Sprintln(args...))
+}
+
+func (g *grpcLogger) Errorf(format string, args ...interface{}) {
+	g.logger.logMessage(LevelError, fmt.Sprintf(format, args...))
+}
+
+// Fatal 写入全部日志后退出
+func (g *grpcLogger) Fatal(args ...interface{}) {
+	g.fatal(fmt.Sprint(args...))
+}
+
+func (g *grpcLogger) Fatalln(args ...interface{}) {
+	g.fatal(fmt.Sprintln(args...))
+}
+
+func (g *grpcLogger) Fatalf(format string, args ...interface{}) {
+	g.fatal(fmt.Sprintf(format, args...))
+}
+
+func (g *grpcLogger) fatal(msg string) {
+	g.logger.outputMessage(logrus.FatalLevel, msg)
+	g.logger.Close()
+	os.Exit(1)
+}
+
+// V grpc 的详细程度是否不低于 l
+func (g *grpcLogger) V(l int) bool {
+	return l <= g.verbosity
+}
@@ -0,0 +1,162 @@
+package klog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AsyncPolicy 异步写入策略
+type AsyncPolicy struct {
+	QueueSize     int           // 队列长度，默认 4096 条
+	Block         bool          // 队列满时阻塞等待，默认丢弃并计数
+	BufferSize    int           // 批量写入的字节数，默认 256KB
+	FlushInterval time.Duration // 定时写入间隔，默认1秒
+}
+
+// AsyncWriter 异步写入的 io.Writer，由单个协程按顺序写入，不影响请求耗时
+type AsyncWriter struct {
+	w      io.Writer
+	policy AsyncPolicy
+
+	mu      sync.RWMutex
+	closed  bool
+	queue   chan []byte
+	flushCh chan chan error
+	done    chan struct{}
+	syncMu  sync.Mutex // 关闭后同步写入
+
+	dropped uint64
+}
+
+var _ io.WriteCloser = (*AsyncWriter)(nil)
+
+// NewAsyncWriter 创建异步写入的 io.Writer
+func NewAsyncWriter(w io.Writer, policy AsyncPolicy) *AsyncWriter {
+	if policy.QueueSize <= 0 {
+		policy.QueueSize = 4096
+	}
+	if policy.BufferSize <= 0 {
+		policy.BufferSize = 256 << 10
+	}
+	if policy.FlushInterval <= 0 {
+		policy.FlushInterval = time.Second
+	}
+	a := &AsyncWriter{
+		w:       w,
+		policy:  policy,
+		queue:   make(chan []byte, policy.QueueSize),
+		flushCh: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Write 放入队列，队列满时按策略阻塞或丢弃；关闭后直接写入
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		// 等待队列写完，保证顺序
+		<-a.done
+		a.syncMu.Lock()
+		defer a.syncMu.Unlock()
+		return a.w.Write(p)
+	}
+
+	b := make([]byte, len(p))
+	copy(b, p)
+	if a.policy.Block {
+		a.queue <- b
+		return len(p), nil
+	}
+	select {
+	case a.queue <- b:
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Dropped 队列满时丢弃的条数
+func (a *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Flush 写入队列中已有的日志
+func (a *AsyncWriter) Flush() error {
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return nil
+	}
+	done := make(chan error, 1)
+	a.flushCh <- done
+	a.mu.RUnlock()
+	return <-done
+}
+
+// Close 写入队列中的全部日志并关闭底层 io.Writer
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	<-a.done
+	if dropped := a.Dropped(); dropped > 0 {
+		fmt.Fprintf(os.Stderr, "klog: %d entries dropped by async writer\n", dropped)
+	}
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.policy.FlushInterval)
+	defer ticker.Stop()
+
+	var buf bytes.Buffer
+	write := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		_, err := a.w.Write(buf.Bytes())
+		buf.Reset()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "klog: async write failed: %s\n", err)
+		}
+		return err
+	}
+	for {
+		select {
+		case p, ok := <-a.queue:
+			if !ok {
+				write()
+				return
+			}
+			buf.Write(p)
+			if buf.Len() >= a.policy.BufferSize {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		case done := <-a.flushCh:
+			for n := len(a.queue); n > 0; n-- {
+				buf.Write(<-a.queue)
+			}
+			done <- write()
+		}
+	}
+}
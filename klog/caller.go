@@ -0,0 +1,107 @@
+package klog
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// PathMode 调用位置中文件路径的输出方式
+type PathMode int
+
+const (
+	PathBase    PathMode = iota // 只保留文件名，文件和控制台格式的默认方式 例：user.go
+	PathPackage                 // 包目录和文件名，caller 字段的默认方式 例：service/user.go
+	PathModule                  // 主模块内为相对模块根目录的路径，其他模块为包路径 例：internal/service/user.go
+	PathFull                    // 完整路径
+)
+
+// CallerPath 调用位置中文件路径的裁剪方式，各格式和控制台共用
+type CallerPath struct {
+	Mode     PathMode
+	Prefixes []string         // 按顺序去除第一个匹配的前缀，匹配后不再按 Mode 处理
+	Patterns []*regexp.Regexp // 按顺序替换为空，设置后不再按 Prefixes、Mode 处理，兼容 SetIgnorePath
+}
+
+// mainModule 主模块和 main 包的路径，由编译信息得到，用于 PathModule
+var mainModule, mainPackage = func() (string, string) {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		return bi.Main.Path, bi.Path
+	}
+	return "", ""
+}()
+
+// Trim 裁剪调用文件路径，function 为调用的函数全名，例：github.com/go-kratos/kratos/v2/log.(*Helper).Info
+func (c CallerPath) Trim(file, function string) string {
+	if len(c.Patterns) > 0 {
+		for _, v := range c.Patterns {
+			file = v.ReplaceAllString(file, "")
+		}
+		return file
+	}
+	for _, prefix := range c.Prefixes {
+		if strings.HasPrefix(file, prefix) {
+			return strings.TrimPrefix(file, prefix)
+		}
+	}
+	switch c.Mode {
+	case PathPackage:
+		return shortPath(file)
+	case PathModule:
+		if p, ok := modulePath(file, function); ok {
+			return p
+		}
+		return shortPath(file)
+	case PathFull:
+		return file
+	default:
+		return filepath.Base(file)
+	}
+}
+
+// Format 输出 "路径:行号"
+func (c CallerPath) Format(frame *runtime.Frame) string {
+	return fmt.Sprintf("%s:%d", c.Trim(frame.File, frame.Function), frame.Line)
+}
+
+// modulePath 由函数所在的包计算文件路径，不依赖编译机器上的目录
+// 主模块内的包去除模块路径，其他包保留完整的包路径（相当于相对 GOPATH/src）
+func modulePath(file, function string) (string, bool) {
+	pkg := packageName(function)
+	if pkg == "main" {
+		pkg = mainPackage
+	}
+	pkg = strings.TrimSuffix(pkg, "_test")
+	if pkg == "" || function == "" {
+		return "", false
+	}
+	base := filepath.Base(file)
+	switch {
+	case mainModule == "":
+		return path.Join(pkg, base), true
+	case pkg == mainModule:
+		return base, true
+	case strings.HasPrefix(pkg, mainModule+"/"):
+		return path.Join(strings.TrimPrefix(pkg, mainModule+"/"), base), true
+	default:
+		return path.Join(pkg, base), true
+	}
+}
+
+// shortPath 只保留最后一级目录和文件名
+func shortPath(file string) string {
+	n := 0
+	for i := len(file) - 1; i > 0; i-- {
+		if file[i] == '/' {
+			n += 1
+			if n >= 2 {
+				return file[i+1:]
+			}
+		}
+	}
+	return file
+}
@@ -0,0 +1,53 @@
+package klog
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// NewRequestIDContext 保存请求ID，logging.Logger 中间件在处理请求前调用
+func NewRequestIDContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext 取出 NewRequestIDContext 保存的请求ID
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok && requestID != ""
+}
+
+// WithContext 返回带有链路信息的 Logger，从 ctx 中读取 tracing.Server 创建的 span
+// 每条日志都会写入 trace.id、span.id、trace.sampled 字段，有请求ID时写入 request.id
+// 例：log.NewHelper(logger.WithContext(ctx)).Info("...")
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return l
+	}
+	entry := l.log.WithContext(ctx)
+	if fields := traceFields(ctx); fields != nil {
+		entry = entry.WithFields(fields)
+	}
+	if requestID, ok := RequestIDFromContext(ctx); ok {
+		entry = entry.WithField(FieldKeyRequestID, requestID)
+	}
+	nl := *l
+	nl.log = entry
+	return &nl
+}
+
+// traceFields ctx 中没有有效的 span 时返回 nil
+func traceFields(ctx context.Context) logrus.Fields {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return nil
+	}
+	return logrus.Fields{
+		FieldKeyTraceID:      spanCtx.TraceID().String(),
+		FieldKeySpanID:       spanCtx.SpanID().String(),
+		FieldKeyTraceSampled: spanCtx.IsSampled(),
+	}
+}
@@ -2,13 +2,83 @@ package klog
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
-	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
-type MyFormatter struct{}
+const defaultTimestampFormat = "2006-01-02 15:04:05.999"
+
+// Format 日志输出格式
+type Format = string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+const (
+	FieldKeyPrefix       = "prefix"
+	FieldKeyCaller       = "caller"
+	FieldKeyTraceID      = "trace.id" // 与 tracing.KeyTraceId 保持一致
+	FieldKeySpanID       = "span.id"
+	FieldKeyTraceSampled = "trace.sampled"
+	FieldKeyRequestID    = "request.id"
+	FieldKeySuppressed   = "suppressed" // 采样或限流丢弃的条数
+	FieldKeyStack        = "stack"
+)
+
+// FieldMap JSON格式输出时各字段的名称，为空则使用默认名称
+type FieldMap struct {
+	Time    string // 默认 time
+	Level   string // 默认 level
+	Caller  string // 默认 caller
+	Prefix  string // 默认 prefix
+	Message string // 默认 msg
+	TraceID string // 默认 trace_id
+	SpanID  string // 默认 span_id
+	Sampled string // 默认 trace_sampled
+}
+
+func (f FieldMap) withDefaults() FieldMap {
+	if f.Time == "" {
+		f.Time = "time"
+	}
+	if f.Level == "" {
+		f.Level = "level"
+	}
+	if f.Caller == "" {
+		f.Caller = "caller"
+	}
+	if f.Prefix == "" {
+		f.Prefix = "prefix"
+	}
+	if f.Message == "" {
+		f.Message = "msg"
+	}
+	if f.TraceID == "" {
+		f.TraceID = "trace_id"
+	}
+	if f.SpanID == "" {
+		f.SpanID = "span_id"
+	}
+	if f.Sampled == "" {
+		f.Sampled = "trace_sampled"
+	}
+	return f
+}
+
+// DefaultFieldOrder 默认优先输出的字段，其余字段按名称排序
+var DefaultFieldOrder = []string{FieldKeyPrefix, FieldKeyCaller, FieldKeyTraceID, FieldKeySpanID, FieldKeyRequestID}
+
+type MyFormatter struct {
+	FieldOrder []string   // 优先输出的字段，为空时使用 DefaultFieldOrder
+	CallerPath CallerPath // 文件路径的裁剪方式，默认只保留文件名
+}
 
 func (m *MyFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	var b *bytes.Buffer
@@ -18,25 +88,107 @@ func (m *MyFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 		b = &bytes.Buffer{}
 	}
 
-	timestamp := entry.Time.Format("2006-01-02 15:04:05.999")
+	timestamp := entry.Time.Format(defaultTimestampFormat)
 	var newLog string
 
 	//HasCaller()为true才会有调用信息
 	if entry.HasCaller() {
-		fName := entry.Caller.File
-		if ignorePath == nil || len(ignorePath) <= 0 {
-			fName = filepath.Base(entry.Caller.File)
-		} else {
-			for _, v := range ignorePath {
-				fName = v.ReplaceAllString(fName, "")
-			}
-		}
 		newLog = fmt.Sprintf("[%s] [%s] [%s:%d] [%s] %s\n",
-			timestamp, entry.Level, fName, entry.Caller.Line, getLogData(entry.Data), entry.Message)
+			timestamp, entry.Level, m.CallerPath.Trim(entry.Caller.File, entry.Caller.Function), entry.Caller.Line, getLogData(entry.Data, m.FieldOrder), entry.Message)
 	} else {
 		newLog = fmt.Sprintf("[%s] [%s] %s\n", timestamp, entry.Level, entry.Message)
 	}
 
 	b.WriteString(newLog)
+	if stack, ok := entry.Data[FieldKeyStack].(Stack); ok {
+		b.WriteString(stack.String())
+	}
 	return b.Bytes(), nil
 }
+
+// JSONFormatter 以JSON格式输出日志，每条日志一行
+type JSONFormatter struct {
+	TimestampFormat string
+	FieldMap        FieldMap
+	CallerPath      CallerPath // 文件路径的裁剪方式，默认只保留文件名
+}
+
+func (j *JSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	fieldMap := j.FieldMap.withDefaults()
+	data := make(logrus.Fields, len(entry.Data)+4)
+	for k, v := range entry.Data {
+		switch k {
+		case FieldKeyPrefix:
+			k = fieldMap.Prefix
+		case FieldKeyCaller:
+			k = fieldMap.Caller
+		case FieldKeyTraceID:
+			k = fieldMap.TraceID
+		case FieldKeySpanID:
+			k = fieldMap.SpanID
+		case FieldKeyTraceSampled:
+			k = fieldMap.Sampled
+		}
+		if err, ok := v.(error); ok {
+			// error 类型直接序列化会得到 {}
+			v = err.Error()
+		}
+		data[k] = v
+	}
+
+	timestampFormat := j.TimestampFormat
+	if timestampFormat == "" {
+		timestampFormat = defaultTimestampFormat
+	}
+	data[fieldMap.Time] = entry.Time.Format(timestampFormat)
+	data[fieldMap.Level] = entry.Level.String()
+	data[fieldMap.Message] = entry.Message
+	// 优先使用 LHook 写入的调用信息
+	if _, ok := data[fieldMap.Caller]; !ok && entry.HasCaller() {
+		data[fieldMap.Caller] = j.CallerPath.Format(entry.Caller)
+	}
+
+	var b *bytes.Buffer
+	if entry.Buffer != nil {
+		b = entry.Buffer
+	} else {
+		b = &bytes.Buffer{}
+	}
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON, %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// sortFields 优先字段按 fieldOrder 的顺序在前，其余字段按名称排序
+func sortFields(keys []string, fieldOrder []string) {
+	if fieldOrder == nil {
+		fieldOrder = DefaultFieldOrder
+	}
+	priority := make(map[string]int, len(fieldOrder))
+	for i, k := range fieldOrder {
+		priority[k] = i
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, iok := priority[keys[i]]
+		pj, jok := priority[keys[j]]
+		switch {
+		case iok && jok:
+			return pi < pj
+		case iok != jok:
+			return iok
+		default:
+			return keys[i] < keys[j]
+		}
+	})
+}
+
+// quoteIfNeeded 包含空格、等号、引号或为空时加引号，保证 k=v 可被无歧义地解析
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
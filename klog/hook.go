@@ -8,72 +8,113 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// DefaultSkipPackages 查找调用位置时跳过的包，子包同样跳过
+var DefaultSkipPackages = []string{
+	"github.com/sirupsen/logrus",
+	"github.com/go-kratos/kratos/v2/log",
+	"log", // 标准库，见 Logger.StdLogger
+	"google.golang.org/grpc/grpclog",
+	"google.golang.org/grpc/internal/grpclog",
+	klogPackage,
+}
+
+// klogPackage 本包的路径，不依赖 go.mod 中的模块名
+var klogPackage = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	return packageName(runtime.FuncForPC(pc).Name())
+}()
+
 type LHook struct {
-	Field     string
-	Skip      int
-	Jumped    int
-	levels    []logrus.Level
-	Formatter func(file, function string, line int) string
+	Field        string
+	Skip         int
+	Jumped       int
+	SkipPackages []string   // 跳过这些包中的调用，找到业务代码的调用位置
+	CallerPath   CallerPath // 文件路径的裁剪方式，NewLHook 创建时为包目录和文件名
+	levels       []logrus.Level
+	Formatter    func(file, function string, line int) string
 }
 
 func (l *LHook) Levels() []logrus.Level {
 	return l.levels
 }
 
+// Fire 写入调用信息，同时修正 SetReportCaller 输出的调用位置
 func (l *LHook) Fire(entry *logrus.Entry) error {
-	entry.Data[l.Field] = l.Formatter(findCaller(l.Skip, l.Jumped))
+	frame, ok := l.findCaller()
+	if !ok {
+		return nil
+	}
+	entry.Data[l.Field] = l.Formatter(l.CallerPath.Trim(frame.File, frame.Function), frame.Function, frame.Line)
+	if entry.Caller != nil {
+		entry.Caller = &frame
+	}
 	return nil
 }
 
-func findCaller(skip, jumped int) (string, string, int) {
-	var (
-		pc       uintptr
-		file     string
-		function string
-		line     int
-	)
-	for i := 0; i < 15; i++ {
-		pc, file, line = getCaller(skip + i)
-		if !strings.HasPrefix(file, "log") && !strings.HasPrefix(file, "logrus") {
-			pc, file, line = getCaller(skip + i + jumped)
-			break
+// findCaller 跳过日志相关包中的调用，再跳过 Jumped 层
+func (l *LHook) findCaller() (runtime.Frame, bool) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(l.Skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	found := false
+	jumped := l.Jumped
+	for {
+		frame, more := frames.Next()
+		if !found && !l.skipFrame(frame) {
+			found = true
+		}
+		if found {
+			if jumped <= 0 {
+				return frame, true
+			}
+			jumped--
+		}
+		if !more {
+			return runtime.Frame{}, false
 		}
 	}
-	if pc != 0 {
-		frames := runtime.CallersFrames([]uintptr{pc})
-		frame, _ := frames.Next()
-		function = frame.Function
-	}
+}
 
-	return file, function, line
+func (l *LHook) skipFrame(frame runtime.Frame) bool {
+	return inPackages(frame, l.SkipPackages)
 }
 
-func getCaller(skip int) (uintptr, string, int) {
-	pc, file, line, ok := runtime.Caller(skip)
-	if !ok {
-		return 0, "", 0
+// inPackages 调用是否属于 pkgs 中的包或其子包
+func inPackages(frame runtime.Frame, pkgs []string) bool {
+	// 日志相关包的测试代码视为业务代码
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
 	}
-
-	n := 0
-	for i := len(file) - 1; i > 0; i-- {
-		if file[i] == '/' {
-			n += 1
-			if n >= 2 {
-				file = file[i+1:]
-				break
-			}
+	pkg := packageName(frame.Function)
+	for _, p := range pkgs {
+		if pkg == p || strings.HasPrefix(pkg, p+"/") {
+			return true
 		}
 	}
+	return false
+}
 
-	return pc, file, line
+// packageName 从函数全名中取出包路径
+// 例：github.com/sirupsen/logrus.(*Entry).Log -> github.com/sirupsen/logrus
+func packageName(function string) string {
+	lastSlash := strings.LastIndexByte(function, '/')
+	if lastSlash < 0 {
+		lastSlash = 0
+	}
+	if dot := strings.IndexByte(function[lastSlash:], '.'); dot >= 0 {
+		return function[:lastSlash+dot]
+	}
+	return function
 }
 
 func NewLHook(jumped int, levels ...logrus.Level) logrus.Hook {
 	hook := LHook{
-		Field:  "caller",
-		Skip:   3,
-		Jumped: jumped,
-		levels: levels,
+		Field:        FieldKeyCaller,
+		Skip:         3,
+		Jumped:       jumped,
+		SkipPackages: DefaultSkipPackages,
+		CallerPath:   CallerPath{Mode: PathPackage},
+		levels:       levels,
 		Formatter: func(file, function string, line int) string {
 			return fmt.Sprintf("%s:%d", file, line)
 		},
@@ -0,0 +1,138 @@
+// Package klogtest 在内存中记录 klog 日志，用于在测试中检查输出的日志
+package klogtest
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darrenyjq/kratos-middleware/klog"
+	"github.com/sirupsen/logrus"
+)
+
+// Entry 一条日志
+type Entry struct {
+	Time    time.Time
+	Level   klog.Level
+	Message string
+	Fields  map[string]interface{} // 不含 caller
+	Caller  string                 // 例：service/user.go:42
+}
+
+// Logger 记录日志的 klog.Logger，可作为 kratos 的 log.Logger 使用
+// 由 WithContext 派生的 Logger 记录到同一个 Logger 中
+type Logger struct {
+	*klog.Logger
+	recorder *recorder
+}
+
+// New 创建记录日志的 Logger，默认记录全部级别且不输出
+// opts 可设置级别、脱敏、采样等，与 klog.New 相同
+func New(opts ...klog.Option) *Logger {
+	r := &recorder{}
+	opts = append([]klog.Option{
+		klog.WithLevel(klog.LevelTrace),
+		klog.WithSinks(klog.Sink{Writer: io.Discard}),
+	}, opts...)
+	l, err := klog.New(append(opts, klog.WithHooks(r))...)
+	if err != nil {
+		panic(err)
+	}
+	return &Logger{Logger: l, recorder: r}
+}
+
+// Entries 已记录的全部日志
+func (l *Logger) Entries() []Entry {
+	l.recorder.mu.Lock()
+	defer l.recorder.mu.Unlock()
+	return append([]Entry(nil), l.recorder.entries...)
+}
+
+// Len 已记录的条数
+func (l *Logger) Len() int {
+	l.recorder.mu.Lock()
+	defer l.recorder.mu.Unlock()
+	return len(l.recorder.entries)
+}
+
+// Contains 是否有日志内容包含 msg
+func (l *Logger) Contains(msg string) bool {
+	for _, e := range l.Entries() {
+		if strings.Contains(e.Message, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterLevel 指定级别的日志，level 无效时返回空
+func (l *Logger) FilterLevel(level klog.Level) []Entry {
+	lvl, err := klog.ParseLevel(level)
+	if err != nil {
+		return nil
+	}
+	var entries []Entry
+	for _, e := range l.Entries() {
+		if e.Level == levelName(lvl) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// FilterField 包含字段 key 且值为 value 的日志
+func (l *Logger) FilterField(key string, value interface{}) []Entry {
+	var entries []Entry
+	for _, e := range l.Entries() {
+		if v, ok := e.Fields[key]; ok && v == value {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Reset 清空已记录的日志
+func (l *Logger) Reset() {
+	l.recorder.mu.Lock()
+	defer l.recorder.mu.Unlock()
+	l.recorder.entries = nil
+}
+
+// recorder 实现 logrus.Hook，在调用信息写入后记录日志
+type recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (r *recorder) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (r *recorder) Fire(entry *logrus.Entry) error {
+	e := Entry{
+		Time:    entry.Time,
+		Level:   levelName(entry.Level),
+		Message: entry.Message,
+		Fields:  make(map[string]interface{}, len(entry.Data)),
+	}
+	for k, v := range entry.Data {
+		if k == klog.FieldKeyCaller {
+			e.Caller, _ = v.(string)
+			continue
+		}
+		e.Fields[k] = v
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e)
+	return nil
+}
+
+// levelName 与 klog 的级别名称一致，logrus 的 warning 为 warn
+func levelName(level logrus.Level) klog.Level {
+	if level == logrus.WarnLevel {
+		return klog.LevelWarn
+	}
+	return level.String()
+}
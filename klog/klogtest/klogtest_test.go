@@ -0,0 +1,37 @@
+package klogtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/darrenyjq/kratos-middleware/klog"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+func TestLogger(t *testing.T) {
+	l := New(klog.WithPrefix("test"))
+	h := log.NewHelper(log.With(l, "service", "user"))
+	h.Infow(log.DefaultMessageKey, "created", "id", 1)
+	h.Warn("slow query")
+	l.WithContext(context.Background()).Log(log.LevelError, log.DefaultMessageKey, "failed")
+
+	if l.Len() != 3 || !l.Contains("slow") || l.Contains("missing") {
+		t.Fatalf("unexpected entries: %+v", l.Entries())
+	}
+	warn := l.FilterLevel(klog.LevelWarn)
+	if len(warn) != 1 || warn[0].Message != "slow query" || warn[0].Fields["service"] != "user" {
+		t.Fatalf("unexpected warn entries: %+v", warn)
+	}
+	if e := l.FilterField("id", 1); len(e) != 1 || e[0].Level != klog.LevelInfo || e[0].Fields[klog.FieldKeyPrefix] != "test" ||
+		!strings.HasPrefix(e[0].Caller, "klogtest/klogtest_test.go:") {
+		t.Fatalf("unexpected info entries: %+v", e)
+	}
+	if len(l.FilterLevel(klog.LevelError)) != 1 {
+		t.Fatal("expected entry logged by derived logger")
+	}
+	l.Reset()
+	if l.Len() != 0 {
+		t.Fatalf("expected no entries after reset, got %d", l.Len())
+	}
+}
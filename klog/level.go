@@ -0,0 +1,93 @@
+package klog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ParseLevel 解析日志级别，忽略大小写和首尾空格
+// 支持 warning、trace 以及 kratos log.Level 的名称（DEBUG、INFO、WARN、ERROR、FATAL）
+func ParseLevel(level string) (logrus.Level, error) {
+	lvl, err := logrus.ParseLevel(strings.TrimSpace(level))
+	if err != nil {
+		return lvl, fmt.Errorf("please set log-level one of %v instead of %q", allLevels, level)
+	}
+	return lvl, nil
+}
+
+// AtomicLevel 可在运行时修改的日志级别
+// 使用 WithAtomicLevel 传入同一个 AtomicLevel 创建的 Logger 共享级别
+type AtomicLevel struct {
+	lvl uint32
+}
+
+var _ http.Handler = (*AtomicLevel)(nil)
+
+// NewAtomicLevel 创建日志级别
+func NewAtomicLevel(level Level) (*AtomicLevel, error) {
+	a := &AtomicLevel{}
+	if err := a.SetLevel(level); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Level 当前日志级别
+func (a *AtomicLevel) Level() Level {
+	return logrus.Level(atomic.LoadUint32(&a.lvl)).String()
+}
+
+// SetLevel 修改日志级别，立即对所有共享该级别的 Logger 生效
+func (a *AtomicLevel) SetLevel(level Level) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&a.lvl, uint32(lvl))
+	return nil
+}
+
+// Enabled 是否输出该级别的日志
+func (a *AtomicLevel) Enabled(level logrus.Level) bool {
+	return logrus.Level(atomic.LoadUint32(&a.lvl)) >= level
+}
+
+type levelPayload struct {
+	Level Level `json:"level"`
+}
+
+// ServeHTTP GET 返回当前级别，PUT 修改级别
+// 修改级别：curl -X PUT -d '{"level":"debug"}' http://127.0.0.1:8000/debug/log/level
+// 挂载到 kratos http.Server：srv.Handle("/debug/log/level", atomicLevel)
+func (a *AtomicLevel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req levelPayload
+		if level := r.URL.Query().Get("level"); level != "" {
+			req.Level = level
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeLevelError(w, http.StatusBadRequest, fmt.Sprintf("request body must be like {\"level\":\"debug\"}: %v", err))
+			return
+		}
+		if err := a.SetLevel(req.Level); err != nil {
+			writeLevelError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		writeLevelError(w, http.StatusMethodNotAllowed, "only GET and PUT are supported")
+		return
+	}
+	json.NewEncoder(w).Encode(levelPayload{Level: a.Level()})
+}
+
+func writeLevelError(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -1,25 +1,29 @@
 package klog
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
-	"time"
 
+	"github.com/darrenyjq/kratos-middleware/redact"
 	"github.com/go-kratos/kratos/v2/log"
-	rotatelogs "github.com/lestrrat/go-file-rotatelogs"
+	"github.com/go-kratos/kratos/v2/transport"
 	"github.com/rifflock/lfshook"
 	"github.com/sirupsen/logrus"
 )
 
 var (
-	isLogFile  bool                   // 是否写入文件
-	logDir     string                 // 目录
-	logFile    string                 // 文件名
-	ignorePath map[int]*regexp.Regexp // 忽略路径前缀
+	isLogFile  bool             // 是否写入文件
+	logDir     string           // 目录
+	logFile    string           // 文件名
+	ignorePath []*regexp.Regexp // 忽略路径前缀，按顺序替换
+
+	logFormat    Format   // 输出格式
+	jsonFieldMap FieldMap // JSON格式字段名称
 )
 
 // Level 日志级别
@@ -32,26 +36,106 @@ const (
 	LevelError Level = "error"
 	LevelWarn  Level = "warn"
 	LevelPanic Level = "panic"
+	LevelTrace Level = "trace"
 )
 
 var (
-	allLevels = []string{LevelDebug, LevelInfo, LevelFatal, LevelError, LevelWarn, LevelPanic}
+	allLevels = []string{LevelDebug, LevelInfo, LevelFatal, LevelError, LevelWarn, LevelPanic, LevelTrace}
 )
 
 type Logger struct {
-	log *logrus.Entry
+	log        *logrus.Entry
+	level      *AtomicLevel
+	closers    []io.Closer // 文件及异步写入，Close 时关闭
+	sampler    *sampler
+	redactor   *redact.Redactor
+	stacktrace *stacktrace
 }
 
-var _ log.Logger = (*Logger)(nil)
+var (
+	_ log.Logger       = (*Logger)(nil)
+	_ transport.Server = (*Logger)(nil)
+)
 
 // Log Implementation of logger interface.
 func (l *Logger) Log(level log.Level, keyVals ...interface{}) error {
-	// l.log.Info(level, keyVals)
 	logLevel, _ := logrus.ParseLevel(level.String())
-	l.log.Log(logLevel, keyVals...)
+	return l.output(logLevel, keyVals)
+}
+
+// output 按 logrus 的级别输出，供 Log 和各适配器调用
+func (l *Logger) output(logLevel logrus.Level, keyVals []interface{}) error {
+	if !l.level.Enabled(logLevel) {
+		return nil
+	}
+	msg, fields := parseKeyVals(keyVals)
+	if l.redactor != nil {
+		for k, v := range fields {
+			fields[k] = l.redactor.Field(k, v)
+		}
+	}
+	if stack := l.stacktrace.stack(logLevel, keyVals); stack != nil {
+		fields[FieldKeyStack] = stack
+	}
+	ok, suppressed := l.sampler.check(logLevel, msg, fields)
+	if suppressed > 0 {
+		l.log.WithField(FieldKeySuppressed, suppressed).Log(logLevel, fmt.Sprintf("suppressed %d entries: %s", suppressed, msg))
+	}
+	if ok {
+		l.log.WithFields(fields).Log(logLevel, msg)
+	}
 	return nil
 }
 
+// parseKeyVals 将 kratos 的 keyVals 按键值对解析为 logrus.Fields
+// 键为 log.DefaultMessageKey 的值作为日志内容，个数为奇数时补齐 "KEYVALS UNPAIRED"
+func parseKeyVals(keyVals []interface{}) (string, logrus.Fields) {
+	if len(keyVals) == 0 {
+		return "", logrus.Fields{}
+	}
+	if len(keyVals)%2 != 0 {
+		keyVals = append(keyVals[:len(keyVals):len(keyVals)], "KEYVALS UNPAIRED")
+	}
+	var msg string
+	fields := make(logrus.Fields, len(keyVals)/2)
+	for i := 0; i < len(keyVals); i += 2 {
+		key, ok := keyVals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyVals[i])
+		}
+		if key == log.DefaultMessageKey {
+			msg = fmt.Sprint(fieldValue(keyVals[i+1]))
+			continue
+		}
+		fields[key] = fieldValue(keyVals[i+1])
+	}
+	return msg, fields
+}
+
+// fieldValue error 和 fmt.Stringer 转为字符串，避免序列化为 {} 或输出指针
+func fieldValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case error:
+		return safeString(val.Error)
+	case fmt.Stringer:
+		return safeString(val.String)
+	default:
+		return v
+	}
+}
+
+// safeString 防止 nil 指针实现的接口在调用时 panic
+func safeString(fn func() string) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			s = "<nil>"
+		}
+	}()
+	return fn()
+}
+
 //func NewLogger(prefix string, level logrus.Level) *Logger {
 //	l := newLogger()
 //	l.SetReportCaller(true)
@@ -60,49 +144,181 @@ func (l *Logger) Log(level log.Level, keyVals ...interface{}) error {
 //	}
 //}
 
+// NewLogger 创建日志，输出目标等由 SetFileLogger、SetIgnorePath、SetJSONFormat 设置
+// level 无效时使用 info 级别并输出警告，新代码请使用 New
 func NewLogger(prefix string, level Level) *Logger {
-	l := newLogger()
-	l.SetReportCaller(true)
-	if lvl, err := logrus.ParseLevel(level); err != nil {
-		l.Panicf("Please set log-level one of %v instead of %s.\n\n",
-			allLevels, lvl)
-	} else {
-		l.Level = lvl
+	l, err := newCompatLogger(prefix, level, WithFallbackLevel(LevelInfo))
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// NewLoggerE 同 NewLogger，level 无效或创建文件失败时返回错误
+func NewLoggerE(prefix string, level Level) (*Logger, error) {
+	return newCompatLogger(prefix, level)
+}
+
+func newCompatLogger(prefix string, level Level, extra ...Option) (*Logger, error) {
+	opts := []Option{WithPrefix(prefix), WithLevel(level)}
+	if isLogFile {
+		opts = append(opts, WithFile(logDir, logFile))
+	}
+	if len(ignorePath) > 0 {
+		opts = append(opts, func(o *options) {
+			o.callerPath.Patterns = append(o.callerPath.Patterns, ignorePath...)
+		})
+	}
+	if logFormat == FormatJSON {
+		opts = append(opts, WithJSONFormat(jsonFieldMap))
+	}
+	return New(append(opts, extra...)...)
+}
+
+// New 创建日志，每个 Logger 的输出目标、格式互不影响
+func New(opts ...Option) (*Logger, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	level := o.atomicLevel
+	var levelErr error
+	if level == nil {
+		lvl, err := ParseLevel(o.level)
+		if err != nil {
+			if o.fallbackLevel == "" {
+				return nil, err
+			}
+			levelErr = err
+			if lvl, err = ParseLevel(o.fallbackLevel); err != nil {
+				return nil, err
+			}
+		}
+		level = &AtomicLevel{lvl: uint32(lvl)}
+	}
+	s, err := newSampler(&o)
+	if err != nil {
+		return nil, err
+	}
+	st, err := newStacktrace(&o)
+	if err != nil {
+		return nil, err
+	}
+	l, closers, err := newLogger(&o)
+	if err != nil {
+		return nil, err
+	}
+	// 级别由 AtomicLevel 控制，logrus 输出全部级别
+	l.Level = logrus.TraceLevel
+	l.SetReportCaller(o.reportCaller)
+	entry := logrus.NewEntry(l)
+	if o.prefix != "" {
+		entry = entry.WithField(FieldKeyPrefix, o.prefix)
+	}
+	if levelErr != nil {
+		entry.Warnf("%v, fallback to %s", levelErr, level.Level())
 	}
 	return &Logger{
-		log: l.WithField("prefix", prefix),
+		log:        entry,
+		level:      level,
+		closers:    closers,
+		sampler:    s,
+		redactor:   o.redactor,
+		stacktrace: st,
+	}, nil
+}
+
+// Level 当前日志级别
+func (l *Logger) Level() Level {
+	return l.level.Level()
+}
+
+// SetLevel 运行时修改日志级别，共享同一个 AtomicLevel 的 Logger 同时生效
+func (l *Logger) SetLevel(level Level) error {
+	return l.level.SetLevel(level)
+}
+
+// AtomicLevel 返回日志级别，可用于创建共享级别的 Logger 或挂载 HTTP 接口
+func (l *Logger) AtomicLevel() *AtomicLevel {
+	return l.level
+}
+
+// Sync 写入异步队列中已有的日志
+func (l *Logger) Sync() error {
+	var err error
+	for _, c := range l.closers {
+		if f, ok := c.(interface{ Flush() error }); ok {
+			if e := f.Flush(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// Dropped 异步写入队列已满、Kafka 发送失败等丢弃的日志条数，见 WithAsync、KafkaSink
+func (l *Logger) Dropped() uint64 {
+	var n uint64
+	for _, c := range l.closers {
+		if d, ok := c.(interface{ Dropped() uint64 }); ok {
+			n += d.Dropped()
+		}
 	}
+	return n
 }
 
-func getLogData(data logrus.Fields) string {
+// Close 写入异步队列中的全部日志并关闭文件，之后的日志同步写入
+func (l *Logger) Close() error {
+	return closeAll(l.closers)
+}
+
+// Start 实现 transport.Server，配合 kratos.Server(..., logger) 在应用退出时关闭日志
+func (l *Logger) Start(context.Context) error {
+	return nil
+}
+
+// Stop 实现 transport.Server，应用退出时写入异步队列中的日志
+func (l *Logger) Stop(context.Context) error {
+	return l.Close()
+}
+
+func getLogData(data logrus.Fields, fieldOrder []string) string {
 	if data == nil || len(data) <= 0 {
 		return " "
 	}
-	payload := strings.Builder{}
-	idx := 0
+	keys := make([]string, 0, len(data))
 	for k, v := range data {
+		// 调用栈在日志之后单独输出
+		if _, ok := v.(Stack); ok {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sortFields(keys, fieldOrder)
+
+	payload := strings.Builder{}
+	for idx, k := range keys {
 		if idx > 0 {
 			payload.WriteString(" ")
 		}
-		payload.WriteString(k + "=")
-		payload.WriteString(fmt.Sprintf("%v", v))
-		idx++
+		payload.WriteString(quoteIfNeeded(k) + "=")
+		payload.WriteString(quoteIfNeeded(fmt.Sprintf("%v", data[k])))
 	}
 	return payload.String()
 }
 
-// SetIgnorePath 设置忽略路径
+// SetIgnorePath 设置忽略路径（正则），按顺序替换，仅对 NewLogger 生效
 // _ignorePath 目录 例：/Users/ha666/gopath/src/git.ztosys.com/ZTO_CS/go-contrib/
 func SetIgnorePath(_ignorePath []string) {
 	if _ignorePath != nil && len(_ignorePath) > 0 {
-		ignorePath = make(map[int]*regexp.Regexp)
-		for i, v := range _ignorePath {
-			ignorePath[i] = regexp.MustCompile(v)
+		ignorePath = make([]*regexp.Regexp, 0, len(_ignorePath))
+		for _, v := range _ignorePath {
+			ignorePath = append(ignorePath, regexp.MustCompile(v))
 		}
 	}
 }
 
-// SetFileLogger 设置日志写入文件
+// SetFileLogger 设置日志写入文件，仅对 NewLogger 生效
 // _logDir 目录 例：/data/logs/tenant-sso
 // _logFile 文件名 例：sso.log
 func SetFileLogger(_logDir, _logFile string) {
@@ -114,53 +330,148 @@ func SetFileLogger(_logDir, _logFile string) {
 	logFile = _logFile
 }
 
-func newLogger() *logrus.Logger {
+// SetJSONFormat 设置日志以JSON格式输出，同时作用于控制台和文件，仅对 NewLogger 生效
+// fieldMap 字段名称，未设置的字段使用默认名称
+func SetJSONFormat(fieldMap FieldMap) {
+	logFormat = FormatJSON
+	jsonFieldMap = fieldMap
+}
+
+func newLogger(o *options) (*logrus.Logger, []io.Closer, error) {
 	l := logrus.New()
-	l.AddHook(NewLHook(2))
-	if isLogFile {
+	if o.reportCaller {
+		hook := NewLHook(0).(*LHook)
+		hook.CallerPath = o.callerPath
+		if !o.pathModeSet {
+			hook.CallerPath.Mode = PathPackage
+		}
+		if len(o.skipPackages) > 0 {
+			hook.SkipPackages = append(append([]string{}, DefaultSkipPackages...), o.skipPackages...)
+		}
+		l.AddHook(hook)
+	}
+	for _, hook := range o.hooks {
+		l.AddHook(hook)
+	}
+	if len(o.sinks) > 0 {
+		hooks, closers, err := newSinkHooks(o)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, hook := range hooks {
+			l.AddHook(hook)
+		}
+		l.Out = io.Discard
+		return l, closers, nil
+	}
+	if o.output == OutputFile {
 		src, err := os.OpenFile(os.DevNull, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
 		if err != nil {
-			panic("打开文件出错:" + err.Error())
+			return nil, nil, fmt.Errorf("打开文件出错:%w", err)
 		}
 		l.Out = src
-		filePatten := fmt.Sprintf("%s/%s", logDir, logFile)
-		filePatten = strings.ReplaceAll(filePatten, ".log", ".%Y-%m-%d.log")
-		logWriter, _ := rotatelogs.New(
-			filePatten,
-			rotatelogs.WithMaxAge(2*24*time.Hour),     // 文件最大保存时间
-			rotatelogs.WithRotationTime(time.Hour*24), // 日志切割时间间隔
-			rotatelogs.WithClock(rotatelogs.Local),
-			rotatelogs.WithLocation(time.Local),
-		)
-		writeMap := lfshook.WriterMap{
-			logrus.PanicLevel: logWriter,
-			logrus.FatalLevel: logWriter,
-			logrus.ErrorLevel: logWriter,
-			logrus.WarnLevel:  logWriter,
-			logrus.InfoLevel:  logWriter,
-			logrus.DebugLevel: logWriter,
-			logrus.TraceLevel: logWriter,
-		}
-		lfHook := lfshook.NewHook(writeMap, &MyFormatter{})
+		writeMap, closers, err := newWriterMap(o)
+		if err != nil {
+			return nil, nil, err
+		}
+		lfHook := lfshook.NewHook(writeMap, newFileFormatter(o))
 		l.AddHook(lfHook)
-	} else {
-		l.Formatter = &logrus.TextFormatter{
-			TimestampFormat: "2006-01-02 15:04:05.999",
-			ForceColors:     true,
-			FullTimestamp:   true,
-			CallerPrettyfier: func(frame *runtime.Frame) (function string, file string) {
-				fName := frame.File
-				if ignorePath == nil || len(ignorePath) <= 0 {
-					fName = filepath.Base(frame.File)
-				} else {
-					for _, v := range ignorePath {
-						fName = v.ReplaceAllString(fName, "")
-					}
-				}
-				return "", fmt.Sprintf("%s:%d", fName, frame.Line)
-			},
-		}
-		l.SetOutput(os.Stdout)
+		return l, closers, nil
 	}
-	return l
+	l.Formatter = newConsoleFormatter(o)
+	l.SetOutput(os.Stdout)
+	return l, nil, nil
+}
+
+// newWriterMap 各级别对应的文件，未单独设置的级别写入 WithFile 设置的文件
+func newWriterMap(o *options) (writeMap lfshook.WriterMap, closers []io.Closer, err error) {
+	defer func() {
+		if err != nil {
+			closeAll(closers)
+		}
+	}()
+	newWriter := func(filename string, rotation Rotation) (io.Writer, error) {
+		w, err := NewRotateWriter(o.dir, filename, rotation)
+		if err != nil {
+			return nil, err
+		}
+		if o.async != nil {
+			aw := NewAsyncWriter(w, *o.async)
+			closers = append(closers, aw)
+			return aw, nil
+		}
+		closers = append(closers, w)
+		return w, nil
+	}
+
+	writeMap = make(lfshook.WriterMap)
+	if o.filename != "" || o.rotation.Pattern != "" {
+		logWriter, err := newWriter(o.filename, o.rotation)
+		if err != nil {
+			return nil, closers, err
+		}
+		for _, level := range logrus.AllLevels {
+			writeMap[level] = logWriter
+		}
+	}
+	for _, f := range o.levelFiles {
+		rotation := f.Rotation
+		if rotation == (Rotation{}) {
+			rotation = o.rotation
+			rotation.Pattern = ""
+			rotation.LinkName = ""
+		}
+		logWriter, err := newWriter(f.Filename, rotation)
+		if err != nil {
+			return nil, closers, err
+		}
+		for _, level := range f.Levels {
+			lvl, err := ParseLevel(level)
+			if err != nil {
+				return nil, closers, fmt.Errorf("%s: %w", f.Filename, err)
+			}
+			writeMap[lvl] = logWriter
+		}
+	}
+	return writeMap, closers, nil
+}
+
+func closeAll(closers []io.Closer) error {
+	var err error
+	for _, c := range closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func newFileFormatter(o *options) logrus.Formatter {
+	if o.formatter != nil {
+		return o.formatter
+	}
+	if o.format == FormatJSON {
+		return &JSONFormatter{FieldMap: o.fieldMap, CallerPath: o.callerPath}
+	}
+	return &MyFormatter{FieldOrder: o.fieldOrder, CallerPath: o.callerPath}
+}
+
+func newConsoleFormatter(o *options) logrus.Formatter {
+	if o.formatter != nil {
+		return o.formatter
+	}
+	if o.format == FormatJSON {
+		return &JSONFormatter{FieldMap: o.fieldMap, CallerPath: o.callerPath}
+	}
+	return &stackFormatter{&logrus.TextFormatter{
+		TimestampFormat: defaultTimestampFormat,
+		ForceColors:     true,
+		FullTimestamp:   true,
+		SortingFunc: func(keys []string) {
+			sortFields(keys, o.fieldOrder)
+		},
+		CallerPrettyfier: func(frame *runtime.Frame) (function string, file string) {
+			return "", o.callerPath.Format(frame)
+		},
+	}}
 }
@@ -1,9 +1,31 @@
 package klog
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdlog "log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/darrenyjq/kratos-middleware/redact"
+	kerrors "github.com/go-kratos/kratos/v2/errors"
 	"github.com/go-kratos/kratos/v2/log"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestLog(t *testing.T) {
@@ -14,3 +36,489 @@ func TestLog(t *testing.T) {
 	l := NewLogger("test", "debug")
 	l.Log(log.LevelInfo, "初始化应用出错:", "abc", "jsdfl")
 }
+
+func TestJSONFormatter(t *testing.T) {
+	f := &JSONFormatter{FieldMap: FieldMap{Message: "message", TraceID: "tid"}}
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		FieldKeyPrefix:  "test",
+		FieldKeyTraceID: "abc",
+		"user":          1,
+	})
+	entry.Time = time.Now()
+	entry.Level = logrus.InfoLevel
+	entry.Message = "hello"
+	b, err := f.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]interface{})
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{"message": "hello", "tid": "abc", "prefix": "test", "level": "info", "user": float64(1)} {
+		if m[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, m[k])
+		}
+	}
+}
+
+func TestParseKeyVals(t *testing.T) {
+	msg, fields := parseKeyVals([]interface{}{log.DefaultMessageKey, "hello", "err", errors.New("boom"), 1, "one", "odd"})
+	if msg != "hello" {
+		t.Errorf("expected msg hello, got %s", msg)
+	}
+	expected := logrus.Fields{"err": "boom", "1": "one", "odd": "KEYVALS UNPAIRED"}
+	if len(fields) != len(expected) {
+		t.Fatalf("expected %d fields, got %v", len(expected), fields)
+	}
+	for k, v := range expected {
+		if fields[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, fields[k])
+		}
+	}
+}
+
+func TestNewFileLogger(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for _, dir := range dirs {
+		l, err := New(WithPrefix("test"), WithLevel(LevelDebug), WithFile(dir, "app.log"), WithPattern("app.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Log(log.LevelInfo, log.DefaultMessageKey, dir)
+	}
+	for _, dir := range dirs {
+		b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), dir) {
+			t.Errorf("expected %s to contain %s, got %s", dir, dir, b)
+		}
+		// 未设置 WithPathMode 时与之前的版本一致
+		if !strings.Contains(string(b), "] [logger_test.go:") || !strings.Contains(string(b), "caller=klog/logger_test.go:") {
+			t.Errorf("unexpected caller %s", b)
+		}
+	}
+	if _, err := New(WithLevel("verbose")); err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
+
+func TestLevelFiles(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(WithFile(dir, "info.log"), WithPattern("info.log"), WithLevelFiles(LevelFile{
+		Levels:   []Level{LevelWarn, LevelError},
+		Filename: "error.log",
+		Rotation: Rotation{Pattern: "error.log", MaxAge: 30 * 24 * time.Hour},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(log.LevelInfo, log.DefaultMessageKey, "info message")
+	l.Log(log.LevelError, log.DefaultMessageKey, "error message")
+	for file, msg := range map[string]string{"info.log": "info message", "error.log": "error message"} {
+		b, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], msg) {
+			t.Errorf("%s: expected only %s, got %s", file, msg, b)
+		}
+	}
+}
+
+func TestAtomicLevel(t *testing.T) {
+	level, err := NewAtomicLevel(LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l1, _ := New(WithAtomicLevel(level))
+	l2, _ := New(WithAtomicLevel(level))
+
+	r := httptest.NewRequest(http.MethodPut, "/debug/log/level", strings.NewReader(`{"level":"debug"}`))
+	w := httptest.NewRecorder()
+	level.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d %s", w.Code, w.Body)
+	}
+	if l1.Level() != LevelDebug || l2.Level() != LevelDebug {
+		t.Errorf("expected debug, got %s %s", l1.Level(), l2.Level())
+	}
+
+	if err = l1.SetLevel("verbose"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	if err = l1.SetLevel(LevelError); err != nil || l2.Level() != LevelError {
+		t.Errorf("expected error, got %s %v", l2.Level(), err)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	ctx = NewRequestIDContext(ctx, "req-1")
+	l := NewLogger("test", LevelDebug).WithContext(ctx)
+	expected := logrus.Fields{
+		FieldKeyTraceID:      traceID.String(),
+		FieldKeySpanID:       spanID.String(),
+		FieldKeyTraceSampled: true,
+		FieldKeyRequestID:    "req-1",
+	}
+	for k, v := range expected {
+		if l.log.Data[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, l.log.Data[k])
+		}
+	}
+	if l = NewLogger("test", LevelDebug).WithContext(context.Background()); l.log.Data[FieldKeyTraceID] != nil {
+		t.Errorf("expected no trace id, got %v", l.log.Data[FieldKeyTraceID])
+	}
+	if _, ok := l.log.Data[FieldKeyRequestID]; ok {
+		t.Errorf("expected no request id, got %v", l.log.Data[FieldKeyRequestID])
+	}
+}
+
+func TestInvalidLevel(t *testing.T) {
+	for _, level := range []string{"warning", "TRACE", log.LevelWarn.String(), " info "} {
+		if _, err := ParseLevel(level); err != nil {
+			t.Errorf("%s: %v", level, err)
+		}
+	}
+	if _, err := NewLoggerE("test", "verbose"); err == nil || !strings.Contains(err.Error(), "verbose") {
+		t.Errorf("expected error with the invalid level, got %v", err)
+	}
+	if l := NewLogger("test", "verbose"); l.Level() != LevelInfo {
+		t.Errorf("expected fallback to info, got %s", l.Level())
+	}
+	if l, err := New(WithKratosLevel(log.LevelError)); err != nil || l.Level() != LevelError {
+		t.Errorf("expected error, got %v", err)
+	}
+}
+
+func TestAsyncFile(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(WithFile(dir, "app.log"), WithPattern("app.log"), WithAsync(AsyncPolicy{Block: true, QueueSize: 8}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Log(log.LevelInfo, log.DefaultMessageKey, fmt.Sprintf("line-%03d", i))
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 100 {
+		t.Fatalf("expected 100 lines, got %d", len(lines))
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, fmt.Sprintf("line-%03d", i)) {
+			t.Fatalf("line %d out of order: %s", i, line)
+		}
+	}
+}
+
+func TestAsyncDropped(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(WithFile(dir, "app.log"), WithPattern("app.log"), WithAsync(AsyncPolicy{QueueSize: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = 2000
+	for i := 0; i < total; i++ {
+		l.Log(log.LevelInfo, log.DefaultMessageKey, "line")
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	// 写入的条数加丢弃的条数等于总数
+	if written := uint64(strings.Count(string(b), "\n")); written+l.Dropped() != total {
+		t.Errorf("expected %d entries, got %d written and %d dropped", total, written, l.Dropped())
+	}
+}
+
+func TestSampling(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(WithFile(dir, "app.log"), WithPattern("app.log"),
+		WithSampling(Sampling{Tick: time.Hour, First: 2, Thereafter: 3}, LevelWarn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Log(log.LevelWarn, log.DefaultMessageKey, "same")
+		l.Log(log.LevelInfo, log.DefaultMessageKey, "info")
+	}
+	// 重置周期后输出被丢弃的条数
+	for i := range l.sampler.samplers[logrus.WarnLevel].counters {
+		l.sampler.samplers[logrus.WarnLevel].counters[i].resetAt = 0
+	}
+	l.Log(log.LevelWarn, log.DefaultMessageKey, "same")
+
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第1、2、5、8条及新周期第1条
+	if n := strings.Count(string(b), "] same\n"); n != 5 {
+		t.Errorf("expected 5 sampled warn lines, got %d", n)
+	}
+	if n := strings.Count(string(b), "] info\n"); n != 8 {
+		t.Errorf("expected 8 info lines, got %d", n)
+	}
+	if !strings.Contains(string(b), "suppressed 4 entries: same") {
+		t.Errorf("expected suppressed summary, got %s", b)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s, _ := newSampler(&options{rateLimit: map[Level]RateLimit{LevelError: {Rate: 1, Burst: 2}}})
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if ok, _ := s.check(logrus.ErrorLevel, "boom", nil); ok {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("expected burst of 2, got %d", allowed)
+	}
+	if ok, _ := s.check(logrus.InfoLevel, "boom", nil); !ok {
+		t.Error("expected info not limited")
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(WithFile(dir, "app.log"), WithPattern("app.log"), WithRedactor(redact.Default()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(log.LevelInfo, log.DefaultMessageKey, "login", "password", "123456", "mobile", "13812345678")
+	b, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if strings.Contains(string(b), "123456") || strings.Contains(string(b), "13812345678") {
+		t.Errorf("expected fields to be redacted, got %s", b)
+	}
+}
+
+func TestMyFormatterFieldOrder(t *testing.T) {
+	data := logrus.Fields{
+		"b":             "x y",
+		"a":             "k=v",
+		FieldKeyTraceID: "abc",
+		FieldKeyCaller:  "klog/logger_test.go:1",
+		FieldKeyPrefix:  "test",
+		"c":             "",
+	}
+	expected := `prefix=test caller=klog/logger_test.go:1 trace.id=abc a="k=v" b="x y" c=""`
+	for i := 0; i < 10; i++ {
+		if got := getLogData(data, nil); got != expected {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+	if got := getLogData(data, []string{"c", "b"}); !strings.HasPrefix(got, `c="" b="x y" a="k=v" caller=`) {
+		t.Errorf("unexpected order %s", got)
+	}
+}
+
+func TestCaller(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(WithFile(dir, "app.log"), WithPattern("app.log"), WithJSONFormat(FieldMap{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, file, line, _ := runtime.Caller(0)
+	log.NewHelper(log.With(l, "module", "test")).Info("helper")
+	l.Log(log.LevelInfo, log.DefaultMessageKey, "direct")
+
+	b, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %s", b)
+	}
+	for i, s := range lines {
+		m := make(map[string]interface{})
+		if err = json.Unmarshal([]byte(s), &m); err != nil {
+			t.Fatal(err)
+		}
+		expected := fmt.Sprintf("%s:%d", shortPath(file), line+i+1)
+		if m["caller"] != expected {
+			t.Errorf("expected caller %s, got %v", expected, m["caller"])
+		}
+	}
+}
+
+func TestStacktrace(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(WithFile(dir, "app.log"), WithPattern("app.log"), WithStacktrace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(log.LevelInfo, log.DefaultMessageKey, "info")
+	l.Log(log.LevelError, log.DefaultMessageKey, "error")
+	l.Log(log.LevelError, log.DefaultMessageKey, "wrapped", "err", newStackError())
+
+	b, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	blocks := strings.Split(string(b), "\n[")
+	if len(blocks) != 3 || strings.Contains(blocks[0], "\t") {
+		t.Fatalf("expected stack only for error entries, got %s", b)
+	}
+	// 每行为 "函数 文件:行号"，与模块路径无关
+	if !regexp.MustCompile(`\t\S+/klog\.TestStacktrace \S+/logger_test\.go:\d+\n`).MatchString(blocks[1]) ||
+		strings.Contains(blocks[1], "sirupsen") || strings.Contains(blocks[1], "runtime.goexit") {
+		t.Errorf("unexpected stack %s", blocks[1])
+	}
+	if !strings.Contains(blocks[2], "klog.newStackError ") {
+		t.Errorf("expected stack of the error, got %s", blocks[2])
+	}
+}
+
+func TestStacktraceWithoutKeyVals(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(WithFile(dir, "app.log"), WithPattern("app.log"), WithStacktrace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Log(log.LevelError)
+	b, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(b), "klog.TestStacktraceWithoutKeyVals ") {
+		t.Errorf("expected stack, got %s", b)
+	}
+}
+
+func newStackError() error {
+	return kerrors.InternalServer("INTERNAL", "boom").WithCause(pkgerrors.New("cause"))
+}
+
+func TestSinks(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	producer := mocks.NewAsyncProducer(t, nil)
+	producer.ExpectInputWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		value, _ := msg.Value.Encode()
+		if msg.Topic != "app-log" || !strings.Contains(string(value), `"msg":"error message"`) {
+			return fmt.Errorf("unexpected kafka message: %s %s", msg.Topic, value)
+		}
+		return nil
+	})
+
+	var debug, warn bytes.Buffer
+	l, err := New(WithPrefix("sink"), WithLevel(LevelDebug), WithSinks(
+		Sink{Level: LevelDebug, Writer: &debug},
+		Sink{Level: LevelWarn, Writer: &warn, Formatter: &JSONFormatter{}},
+		SyslogSink("udp", conn.LocalAddr().String(), "klog", LevelError),
+		KafkaSink(producer, "app-log", LevelError),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := log.NewHelper(l)
+	h.Debug("debug message")
+	h.Warn("warn message")
+	h.Error("error message")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(debug.String(), "\n"); n != 3 || !strings.Contains(debug.String(), "[debug]") {
+		t.Fatalf("unexpected debug sink: %s", debug.String())
+	}
+	if n := strings.Count(warn.String(), "\n"); n != 2 || strings.Contains(warn.String(), "debug message") ||
+		!strings.Contains(warn.String(), `"msg":"warn message"`) {
+		t.Fatalf("unexpected warn sink: %s", warn.String())
+	}
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<11>") || !strings.Contains(msg, " klog[") ||
+		!strings.Contains(msg, "error message") {
+		t.Fatalf("unexpected syslog message: %s", msg)
+	}
+
+	// 未收到或内容不符时 Close 报错
+	producer.Close()
+}
+
+func TestAdapters(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(WithLevel(LevelDebug), WithSinks(Sink{Writer: &buf, Formatter: &JSONFormatter{}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.StdLogger(LevelInfo).Printf("[ERROR] std error")
+	// 不能因依赖库的日志 panic
+	l.StdLogger(LevelInfo).Print("panic: recovered from handler")
+	NewSaramaLogger(l).Print("[PANIC] sarama panic")
+	restore := l.RedirectStdLog(LevelWarn)
+	stdlog.Println("std warn")
+	restore()
+	NewSaramaLogger(l).Printf("Failed to connect to broker %s", "127.0.0.1:9092")
+	NewSaramaLogger(l).Println("Connected to broker")
+	NewGRPCLogger(l, 0).Errorf("grpc %s", "error")
+
+	want := []struct{ level, msg string }{
+		{"error", "std error"},
+		{"error", "recovered from handler"},
+		{"error", "sarama panic"},
+		{"warning", "std warn"},
+		{"warning", "Failed to connect to broker 127.0.0.1:9092"},
+		{"info", "Connected to broker"},
+		{"error", "grpc error"},
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+	for i, line := range lines {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &data); err != nil {
+			t.Fatal(err)
+		}
+		if data["level"] != want[i].level || data["msg"] != want[i].msg ||
+			!strings.HasPrefix(data["caller"].(string), "klog/logger_test.go:") {
+			t.Fatalf("unexpected entry %d: %s", i, line)
+		}
+	}
+}
+
+func TestCallerPath(t *testing.T) {
+	pc, file, _, _ := runtime.Caller(0)
+	function := runtime.FuncForPC(pc).Name()
+	tests := []struct {
+		path CallerPath
+		want string
+	}{
+		{CallerPath{}, "logger_test.go"},
+		{CallerPath{Mode: PathPackage}, "klog/logger_test.go"},
+		{CallerPath{Mode: PathModule}, "klog/logger_test.go"},
+		{CallerPath{Mode: PathFull}, file},
+		{CallerPath{Prefixes: []string{"/not/matched/", filepath.Dir(filepath.Dir(file)) + "/", filepath.Dir(file) + "/"}}, "klog/logger_test.go"},
+		{CallerPath{Mode: PathBase, Patterns: []*regexp.Regexp{regexp.MustCompile(`^.*/klog/`), regexp.MustCompile(`_test`)}}, "logger.go"},
+	}
+	for _, tt := range tests {
+		if got := tt.path.Trim(file, function); got != tt.want {
+			t.Errorf("%+v: expected %s, got %s", tt.path, tt.want, got)
+		}
+	}
+	if got, _ := modulePath("/build/app/internal/service/user.go", mainModule+"/internal/service.(*UserService).Create"); got != "internal/service/user.go" {
+		t.Errorf("expected module relative path, got %s", got)
+	}
+	if got, _ := modulePath("/root/go/pkg/mod/github.com/go-kratos/kratos/v2@v2.5.1/log/helper.go", "github.com/go-kratos/kratos/v2/log.(*Helper).Info"); got != "github.com/go-kratos/kratos/v2/log/helper.go" {
+		t.Errorf("expected package path, got %s", got)
+	}
+}
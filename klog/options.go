@@ -0,0 +1,326 @@
+package klog
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/darrenyjq/kratos-middleware/redact"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/sirupsen/logrus"
+)
+
+// Output 日志输出目标
+type Output = string
+
+const (
+	OutputStdout Output = "stdout"
+	OutputFile   Output = "file"
+)
+
+// Option is klog option.
+type Option func(*options)
+
+type options struct {
+	prefix        string
+	level         Level
+	atomicLevel   *AtomicLevel
+	fallbackLevel Level // level 无效时使用的级别
+	output        Output
+	dir           string       // 目录
+	filename      string       // 文件名
+	rotation      Rotation     // 文件切割策略
+	levelFiles    []LevelFile  // 按级别拆分的文件
+	async         *AsyncPolicy // 异步写入文件
+	sinks         []Sink       // 多个输出目标，设置后 output 不再生效
+	sampling      map[Level]Sampling
+	rateLimit     map[Level]RateLimit
+	redactor      *redact.Redactor
+	callerPath    CallerPath // 调用文件路径的裁剪方式
+	pathModeSet   bool       // 是否设置了 WithPathMode，未设置时 caller 字段使用 PathPackage
+	reportCaller  bool       // 是否输出调用信息
+	skipPackages  []string   // 查找调用位置时额外跳过的包
+	stackLevels   []Level    // 输出调用栈的级别
+	format        Format
+	fieldMap      FieldMap
+	fieldOrder    []string
+	formatter     logrus.Formatter
+	hooks         []logrus.Hook
+}
+
+func defaultOptions() options {
+	return options{
+		level:  LevelInfo,
+		output: OutputStdout,
+		rotation: Rotation{
+			RotationTime: 24 * time.Hour,
+			MaxAge:       2 * 24 * time.Hour,
+		},
+		reportCaller: true,
+		format:       FormatText,
+	}
+}
+
+// WithPrefix 设置日志前缀，写入 prefix 字段
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithLevel 设置日志级别
+func WithLevel(level Level) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// WithKratosLevel 使用 kratos 的日志级别
+func WithKratosLevel(level log.Level) Option {
+	return func(o *options) {
+		o.level = level.String()
+	}
+}
+
+// WithFallbackLevel 设置 level 无效时使用的级别，并输出一条警告
+// 未设置时 New 对无效的 level 返回错误
+func WithFallbackLevel(level Level) Option {
+	return func(o *options) {
+		o.fallbackLevel = level
+	}
+}
+
+// WithAtomicLevel 使用可在运行时修改的日志级别，优先于 WithLevel
+// 传入同一个 AtomicLevel 的 Logger 共享级别
+func WithAtomicLevel(level *AtomicLevel) Option {
+	return func(o *options) {
+		o.atomicLevel = level
+	}
+}
+
+// WithOutput 设置输出目标 OutputStdout 或 OutputFile
+func WithOutput(output Output) Option {
+	return func(o *options) {
+		o.output = output
+	}
+}
+
+// WithFile 设置日志写入文件
+// dir 目录 例：/data/logs/tenant-sso
+// filename 文件名 例：sso.log
+func WithFile(dir, filename string) Option {
+	return func(o *options) {
+		o.output = OutputFile
+		o.dir = dir
+		o.filename = filename
+	}
+}
+
+// WithRotation 设置完整的文件切割策略，覆盖默认的保存2天、每天切割
+func WithRotation(rotation Rotation) Option {
+	return func(o *options) {
+		o.rotation = rotation
+	}
+}
+
+// WithPattern 设置切割后的文件名格式（strftime），不含目录
+// 例：sso.%Y-%m-%d.log，默认在文件名后缀前插入 .%Y-%m-%d
+func WithPattern(pattern string) Option {
+	return func(o *options) {
+		o.rotation.Pattern = pattern
+	}
+}
+
+// WithMaxAge 设置文件最大保存时间，默认2天，0 表示不按时间清理
+func WithMaxAge(d time.Duration) Option {
+	return func(o *options) {
+		o.rotation.MaxAge = d
+	}
+}
+
+// WithRotationTime 设置日志切割时间间隔，默认24小时
+func WithRotationTime(d time.Duration) Option {
+	return func(o *options) {
+		o.rotation.RotationTime = d
+	}
+}
+
+// WithMaxSize 设置单个文件最大字节数，超过后按大小切割
+func WithMaxSize(size int64) Option {
+	return func(o *options) {
+		o.rotation.MaxSize = size
+	}
+}
+
+// WithMaxBackups 设置最多保留的历史文件个数
+func WithMaxBackups(n int) Option {
+	return func(o *options) {
+		o.rotation.MaxBackups = n
+	}
+}
+
+// WithCompress 设置是否使用 gzip 压缩历史文件
+func WithCompress(compress bool) Option {
+	return func(o *options) {
+		o.rotation.Compress = compress
+	}
+}
+
+// WithLinkName 设置指向当前文件的软链接，例：current.log
+func WithLinkName(linkName string) Option {
+	return func(o *options) {
+		o.rotation.LinkName = linkName
+	}
+}
+
+// WithSinks 同时写入多个输出目标，每个目标有独立的最低级别和格式，设置后 WithOutput、WithFile 不再生效
+// 例：本地开发 klog.ConsoleSink(klog.LevelDebug)，线上
+// klog.FileSink("/data/logs/sso", "sso.log", klog.LevelInfo)、klog.KafkaSink(producer, "app-log", klog.LevelWarn)
+func WithSinks(sinks ...Sink) Option {
+	return func(o *options) {
+		o.sinks = append(o.sinks, sinks...)
+	}
+}
+
+// LevelFile 按级别拆分的日志文件
+type LevelFile struct {
+	Levels   []Level  // 写入该文件的级别，这些级别不再写入 WithFile 设置的文件
+	Filename string   // 文件名，位于 WithFile 设置的目录下 例：error.log
+	Rotation Rotation // 切割策略，为空时使用 WithRotation 的设置
+}
+
+// WithLevelFiles 设置按级别拆分的日志文件
+// 例：warn、error、fatal、panic 写入 error.log 并保存30天，其余级别写入 WithFile 设置的文件
+func WithLevelFiles(files ...LevelFile) Option {
+	return func(o *options) {
+		o.output = OutputFile
+		o.levelFiles = append(o.levelFiles, files...)
+	}
+}
+
+// WithAsync 设置异步写入文件，退出前需调用 Logger.Close 写入队列中的日志
+func WithAsync(policy AsyncPolicy) Option {
+	return func(o *options) {
+		o.async = &policy
+	}
+}
+
+// WithSampling 设置采样策略，levels 为空时作用于全部级别
+// 例：每秒相同内容的 warn 日志先输出100条，之后每100条输出一条
+func WithSampling(sampling Sampling, levels ...Level) Option {
+	return func(o *options) {
+		if len(levels) == 0 {
+			levels = allLevels
+		}
+		if o.sampling == nil {
+			o.sampling = make(map[Level]Sampling)
+		}
+		for _, level := range levels {
+			o.sampling[level] = sampling
+		}
+	}
+}
+
+// WithRateLimit 设置令牌桶限流，levels 为空时作用于全部级别
+func WithRateLimit(limit RateLimit, levels ...Level) Option {
+	return func(o *options) {
+		if len(levels) == 0 {
+			levels = allLevels
+		}
+		if o.rateLimit == nil {
+			o.rateLimit = make(map[Level]RateLimit)
+		}
+		for _, level := range levels {
+			o.rateLimit[level] = limit
+		}
+	}
+}
+
+// WithRedactor 设置字段脱敏，例：klog.WithRedactor(redact.Default())
+func WithRedactor(r *redact.Redactor) Option {
+	return func(o *options) {
+		o.redactor = r
+	}
+}
+
+// WithIgnorePath 设置调用信息中需要忽略的路径（正则），按顺序替换，设置后 WithPathMode、WithTrimPrefix 不再生效
+// 例：/Users/ha666/gopath/src/git.ztosys.com/ZTO_CS/go-contrib/
+func WithIgnorePath(patterns ...string) Option {
+	return func(o *options) {
+		for _, v := range patterns {
+			o.callerPath.Patterns = append(o.callerPath.Patterns, regexp.MustCompile(v))
+		}
+	}
+}
+
+// WithPathMode 设置调用信息中文件路径的输出方式，同时用于 caller 字段和文件、控制台格式
+// 未设置时 caller 字段为包目录和文件名 例：service/user.go，文件和控制台格式只保留文件名 例：user.go
+// PathModule 输出相对模块根目录的路径，模块由编译信息得到，不依赖编译机器上的目录
+func WithPathMode(mode PathMode) Option {
+	return func(o *options) {
+		o.callerPath.Mode = mode
+		o.pathModeSet = true
+	}
+}
+
+// WithTrimPrefix 设置调用信息中需要去除的路径前缀，按顺序去除第一个匹配的前缀
+// 例：/Users/ha666/go/src/、/builds/
+func WithTrimPrefix(prefixes ...string) Option {
+	return func(o *options) {
+		o.callerPath.Prefixes = append(o.callerPath.Prefixes, prefixes...)
+	}
+}
+
+// WithReportCaller 设置是否输出调用信息，默认输出
+func WithReportCaller(reportCaller bool) Option {
+	return func(o *options) {
+		o.reportCaller = reportCaller
+	}
+}
+
+// WithCallerSkipPackages 查找调用位置时额外跳过的包，用于自行封装的日志工具
+// 默认跳过 logrus、kratos log、标准库 log、grpclog 和 klog
+func WithCallerSkipPackages(pkgs ...string) Option {
+	return func(o *options) {
+		o.skipPackages = append(o.skipPackages, pkgs...)
+	}
+}
+
+// WithStacktrace 设置输出调用栈的级别，为空时为 error、fatal、panic
+// error 携带调用栈时（pkg/errors）使用其调用栈，否则采集当前调用栈
+func WithStacktrace(levels ...Level) Option {
+	return func(o *options) {
+		if len(levels) == 0 {
+			levels = []Level{LevelError, LevelFatal, LevelPanic}
+		}
+		o.stackLevels = levels
+	}
+}
+
+// WithJSONFormat 设置以JSON格式输出
+func WithJSONFormat(fieldMap FieldMap) Option {
+	return func(o *options) {
+		o.format = FormatJSON
+		o.fieldMap = fieldMap
+	}
+}
+
+// WithFieldOrder 设置优先输出的字段，其余字段按名称排序，默认 prefix、caller、trace.id、span.id
+func WithFieldOrder(keys ...string) Option {
+	return func(o *options) {
+		o.fieldOrder = keys
+	}
+}
+
+// WithFormatter 设置自定义格式，优先于 WithJSONFormat
+func WithFormatter(formatter logrus.Formatter) Option {
+	return func(o *options) {
+		o.formatter = formatter
+	}
+}
+
+// WithHooks 添加 logrus 的 Hook，在调用信息写入后执行，例：klogtest 记录日志
+func WithHooks(hooks ...logrus.Hook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, hooks...)
+	}
+}
@@ -0,0 +1,332 @@
+package klog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	strftime "github.com/lestrrat/go-strftime"
+)
+
+// Rotation 文件切割策略
+type Rotation struct {
+	Pattern      string        // 切割后的文件名格式（strftime），例：sso.%Y-%m-%d.log，默认在文件名后缀前插入 .%Y-%m-%d
+	RotationTime time.Duration // 按时间切割的间隔，默认24小时
+	MaxSize      int64         // 单个文件最大字节数，超过后按大小切割，0 表示不按大小切割
+	MaxAge       time.Duration // 文件最大保存时间，0 表示不按时间清理
+	MaxBackups   int           // 最多保留的历史文件个数，0 表示不按个数清理
+	Compress     bool          // 是否使用 gzip 压缩历史文件
+	LinkName     string        // 指向当前文件的软链接，例：current.log，相对路径时位于日志目录下
+}
+
+var patternConversionRegexp = regexp.MustCompile(`%[%+A-Za-z]`)
+
+// conversionRegexps strftime 各格式对应的正则，其余格式匹配不含路径分隔符的任意内容
+var conversionRegexps = map[byte]string{
+	'Y': `\d{4}`, 'C': `\d{2}`, 'y': `\d{2}`, 'm': `\d{2}`, 'd': `\d{2}`, 'e': `[ \d]\d`, 'j': `\d{3}`,
+	'H': `\d{2}`, 'I': `\d{2}`, 'k': `[ \d]\d`, 'l': `[ \d]\d`, 'M': `\d{2}`, 'S': `\d{2}`,
+	'U': `\d{2}`, 'V': `\d{2}`, 'W': `\d{2}`, 'u': `\d`, 'w': `\d`,
+	'F': `\d{4}-\d{2}-\d{2}`, 'D': `\d{2}/\d{2}/\d{2}`, 'T': `\d{2}:\d{2}:\d{2}`, 'R': `\d{2}:\d{2}`,
+	'a': `[A-Za-z]+`, 'A': `[A-Za-z]+`, 'b': `[A-Za-z]+`, 'B': `[A-Za-z]+`, 'h': `[A-Za-z]+`, 'p': `[AP]M`,
+	'%': `%`,
+}
+
+// backupRegexp 按切割格式生成的文件及其按大小切割、压缩后的文件，例：sso.%Y-%m-%d.log 匹配
+// sso.2022-01-02.log、sso.2022-01-02.log.1、sso.2022-01-02.log.1.gz，不匹配 sso.error.2022-01-02.log
+func backupRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range patternConversionRegexp.FindAllStringIndex(pattern, -1) {
+		b.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		if re, ok := conversionRegexps[pattern[loc[0]+1]]; ok {
+			b.WriteString(re)
+		} else {
+			b.WriteString(`[^/\\]+`)
+		}
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(pattern[last:]))
+	b.WriteString(`(\.\d+)?(\.gz)?$`)
+	return regexp.MustCompile(b.String())
+}
+
+// RotateWriter 按时间和大小切割的文件 io.Writer
+type RotateWriter struct {
+	rotation    Rotation
+	pattern     *strftime.Strftime
+	globPattern string
+	backupRe    *regexp.Regexp
+	linkName    string
+
+	mu    sync.Mutex
+	file  *os.File
+	curFn string
+	size  int64
+
+	millCh chan struct{} // 由 mu 保护，Close 时关闭
+	millMu sync.Mutex
+}
+
+var _ io.WriteCloser = (*RotateWriter)(nil)
+
+// NewRotateWriter 创建按策略切割的文件
+// dir 目录 例：/data/logs/tenant-sso
+// filename 文件名 例：sso.log，Rotation.Pattern 为空时用于生成切割格式
+func NewRotateWriter(dir, filename string, rotation Rotation) (*RotateWriter, error) {
+	if rotation.Pattern == "" {
+		if filename == "" {
+			return nil, fmt.Errorf("klog: filename and rotation pattern are both empty")
+		}
+		ext := filepath.Ext(filename)
+		rotation.Pattern = strings.TrimSuffix(filename, ext) + ".%Y-%m-%d" + ext
+	}
+	if rotation.RotationTime <= 0 {
+		rotation.RotationTime = 24 * time.Hour
+	}
+	pattern, err := strftime.New(filepath.Join(dir, rotation.Pattern))
+	if err != nil {
+		return nil, fmt.Errorf("klog: invalid rotation pattern %s: %w", rotation.Pattern, err)
+	}
+	w := &RotateWriter{
+		rotation:    rotation,
+		pattern:     pattern,
+		globPattern: patternConversionRegexp.ReplaceAllString(pattern.Pattern(), "*") + "*",
+		backupRe:    backupRegexp(pattern.Pattern()),
+	}
+	if rotation.LinkName != "" {
+		w.linkName = rotation.LinkName
+		if !filepath.IsAbs(w.linkName) {
+			w.linkName = filepath.Join(dir, w.linkName)
+		}
+	}
+	return w, nil
+}
+
+// Write 写入当前文件，到达切割时间或大小时先切割
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	filename := w.genFilename()
+	if w.file == nil || filename != w.curFn {
+		if err := w.openFile(filename); err != nil {
+			return 0, err
+		}
+	} else if w.rotation.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.rotation.MaxSize {
+		if err := w.rotateBySize(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// CurrentFileName 当前写入的文件名
+func (w *RotateWriter) CurrentFileName() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.curFn
+}
+
+// Close 关闭当前文件并结束处理历史文件的协程，之后再写入时重新打开
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.millCh != nil {
+		close(w.millCh)
+		w.millCh = nil
+	}
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// genFilename 按切割间隔取整后的本地时间生成文件名
+func (w *RotateWriter) genFilename() string {
+	now := time.Now()
+	_, offset := now.Zone()
+	zone := time.Duration(offset) * time.Second
+	t := now.Add(zone).Truncate(w.rotation.RotationTime).Add(-zone)
+	return w.pattern.FormatString(t)
+}
+
+// must be locked during this operation
+func (w *RotateWriter) openFile(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("klog: failed to create log dir: %w", err)
+	}
+	fh, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("klog: failed to open file %s: %w", filename, err)
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return err
+	}
+
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.file = fh
+	w.curFn = filename
+	w.size = info.Size()
+
+	if w.linkName != "" {
+		if err = w.link(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "klog: failed to link %s: %s\n", w.linkName, err)
+		}
+	}
+	w.startMill()
+	return nil
+}
+
+// must be locked during this operation
+func (w *RotateWriter) rotateBySize() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	backup := w.backupName(w.curFn)
+	if err := os.Rename(w.curFn, backup); err != nil {
+		return fmt.Errorf("klog: failed to rename %s: %w", w.curFn, err)
+	}
+	fh, err := os.OpenFile(w.curFn, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("klog: failed to open file %s: %w", w.curFn, err)
+	}
+	w.file = fh
+	w.size = 0
+	w.startMill()
+	return nil
+}
+
+// backupName 按大小切割的历史文件名，例：sso.2022-01-02.log.1
+func (w *RotateWriter) backupName(filename string) string {
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s.%d", filename, i)
+		if _, err := os.Stat(name); err == nil {
+			continue
+		}
+		if _, err := os.Stat(name + ".gz"); err == nil {
+			continue
+		}
+		return name
+	}
+}
+
+func (w *RotateWriter) link(filename string) error {
+	tmpLinkName := filename + "_symlink"
+	os.Remove(tmpLinkName)
+	if err := os.Symlink(filename, tmpLinkName); err != nil {
+		return err
+	}
+	return os.Rename(tmpLinkName, w.linkName)
+}
+
+// startMill 通知后台协程处理历史文件，协程在打开文件时启动，Close 时退出
+// must be locked during this operation
+func (w *RotateWriter) startMill() {
+	if w.millCh == nil {
+		ch := make(chan struct{}, 1)
+		w.millCh = ch
+		go func() {
+			for range ch {
+				w.mill()
+			}
+		}()
+	}
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+// mill 压缩历史文件，并按保存时间和个数清理
+func (w *RotateWriter) mill() {
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	if !w.rotation.Compress && w.rotation.MaxAge <= 0 && w.rotation.MaxBackups <= 0 {
+		return
+	}
+	current := w.CurrentFileName()
+	matches, err := filepath.Glob(w.globPattern)
+	if err != nil {
+		return
+	}
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+	backups := make([]backup, 0, len(matches))
+	for _, path := range matches {
+		// 同一目录下其他格式的文件，例：按级别拆分的 sso.error.2022-01-02.log
+		if path == current || path == w.linkName || !w.backupRe.MatchString(path) {
+			continue
+		}
+		fi, err := os.Lstat(path)
+		if err != nil || fi.Mode()&os.ModeSymlink != 0 || fi.IsDir() {
+			continue
+		}
+		if w.rotation.Compress && !strings.HasSuffix(path, ".gz") {
+			if err = compressFile(path); err != nil {
+				fmt.Fprintf(os.Stderr, "klog: failed to compress %s: %s\n", path, err)
+				continue
+			}
+			path += ".gz"
+		}
+		backups = append(backups, backup{path: path, modTime: fi.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+
+	cutoff := time.Now().Add(-w.rotation.MaxAge)
+	for i, b := range backups {
+		if (w.rotation.MaxBackups > 0 && i >= w.rotation.MaxBackups) ||
+			(w.rotation.MaxAge > 0 && b.modTime.Before(cutoff)) {
+			os.Remove(b.path)
+		}
+	}
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
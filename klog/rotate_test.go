@@ -0,0 +1,91 @@
+package klog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateWriterMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(dir, "app", Rotation{
+		MaxSize:    10,
+		MaxBackups: 2,
+		Compress:   true,
+		LinkName:   "current",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for i := 0; i < 5; i++ {
+		if _, err = w.Write([]byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.HasPrefix(filepath.Base(w.CurrentFileName()), "app."+time.Now().Format("2006-01-02")) {
+		t.Errorf("unexpected file name %s", w.CurrentFileName())
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "current")); err != nil || target != w.CurrentFileName() {
+		t.Errorf("expected link to %s, got %s %v", w.CurrentFileName(), target, err)
+	}
+
+	w.mill()
+	backups, _ := filepath.Glob(w.CurrentFileName() + ".*.gz")
+	if len(backups) != 2 {
+		t.Errorf("expected 2 compressed backups, got %v", backups)
+	}
+}
+
+func TestRotateWriterSharedDir(t *testing.T) {
+	dir := t.TempDir()
+	main, err := NewRotateWriter(dir, "sso.log", Rotation{Compress: true, MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer main.Close()
+	errw, err := NewRotateWriter(dir, "sso.error.log", Rotation{MaxAge: 30 * 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer errw.Close()
+	old := time.Now().Add(-48 * time.Hour)
+	for i, name := range []string{"sso.2020-01-01.log", "sso.2020-01-02.log.1", "sso.error.2020-01-01.log"} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(name), 0644)
+		os.Chtimes(path, old.Add(time.Duration(i)*time.Hour), old.Add(time.Duration(i)*time.Hour))
+	}
+	main.Write([]byte("main\n"))
+	errw.Write([]byte("error\n"))
+
+	main.mill()
+	for _, name := range []string{filepath.Base(errw.CurrentFileName()), "sso.error.2020-01-01.log", "sso.2020-01-02.log.1.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to exist: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sso.2020-01-01.log.gz")); !os.IsNotExist(err) {
+		t.Errorf("expected the oldest backup to be removed, got %v", err)
+	}
+}
+
+func TestRotateWriterReopen(t *testing.T) {
+	w, err := NewRotateWriter(t.TempDir(), "app.log", Rotation{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("first\n"))
+	if err = w.Close(); err != nil || w.millCh != nil {
+		t.Fatalf("expected the mill goroutine to stop: %v", err)
+	}
+	if _, err = w.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	b, _ := os.ReadFile(w.CurrentFileName())
+	if string(b) != "first\nsecond\n" {
+		t.Errorf("unexpected content %q", b)
+	}
+}
@@ -0,0 +1,162 @@
+package klog
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	samplerSlots   = 4096  // 每个级别的采样计数槽位，按内容哈希
+	limiterMaxKeys = 10000 // 限流 key 的最大个数，超过后清空
+)
+
+// Sampling 采样策略，同一级别、同一内容的日志在每个周期内先输出 First 条，之后每 Thereafter 条输出一条
+type Sampling struct {
+	Tick       time.Duration // 周期，默认1秒
+	First      int           // 每个周期先输出的条数
+	Thereafter int           // 之后每多少条输出一条，0 表示全部丢弃
+}
+
+// RateLimit 令牌桶限流，同一个 key 每秒最多输出 Rate 条，允许突发 Burst 条
+type RateLimit struct {
+	Rate  float64
+	Burst int
+	// Key 限流的 key，默认按日志内容
+	Key func(msg string, fields logrus.Fields) string
+}
+
+type samplingCounter struct {
+	resetAt    int64
+	count      uint64
+	suppressed uint64
+}
+
+type levelSampler struct {
+	sampling Sampling
+	counters [samplerSlots]samplingCounter
+}
+
+type tokenBucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed uint64
+}
+
+type levelLimiter struct {
+	limit   RateLimit
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// sampler 按级别采样和限流，同一个 Logger 派生出的 Logger 共享
+type sampler struct {
+	samplers [logrus.TraceLevel + 1]*levelSampler
+	limiters [logrus.TraceLevel + 1]*levelLimiter
+}
+
+func newSampler(o *options) (*sampler, error) {
+	if len(o.sampling) == 0 && len(o.rateLimit) == 0 {
+		return nil, nil
+	}
+	s := &sampler{}
+	for level, sampling := range o.sampling {
+		lvl, err := ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		if sampling.Tick <= 0 {
+			sampling.Tick = time.Second
+		}
+		s.samplers[lvl] = &levelSampler{sampling: sampling}
+	}
+	for level, limit := range o.rateLimit {
+		lvl, err := ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		if limit.Burst <= 0 {
+			limit.Burst = 1
+		}
+		s.limiters[lvl] = &levelLimiter{limit: limit, buckets: make(map[string]*tokenBucket)}
+	}
+	return s, nil
+}
+
+// check 是否输出该条日志，suppressed 为上一周期被丢弃的条数，大于0时需输出汇总
+func (s *sampler) check(level logrus.Level, msg string, fields logrus.Fields) (ok bool, suppressed uint64) {
+	if s == nil || int(level) >= len(s.samplers) {
+		return true, 0
+	}
+	now := time.Now()
+	ok = true
+	if ls := s.samplers[level]; ls != nil {
+		ok, suppressed = ls.check(now, msg)
+	}
+	if ll := s.limiters[level]; ll != nil && ok {
+		var n uint64
+		ok, n = ll.check(now, msg, fields)
+		suppressed += n
+	}
+	return ok, suppressed
+}
+
+func (ls *levelSampler) check(now time.Time, msg string) (bool, uint64) {
+	h := fnv.New32a()
+	h.Write([]byte(msg))
+	c := &ls.counters[h.Sum32()%samplerSlots]
+
+	var n, suppressed uint64
+	tn := now.UnixNano()
+	resetAt := atomic.LoadInt64(&c.resetAt)
+	if resetAt > tn {
+		n = atomic.AddUint64(&c.count, 1)
+	} else if atomic.CompareAndSwapInt64(&c.resetAt, resetAt, tn+ls.sampling.Tick.Nanoseconds()) {
+		atomic.StoreUint64(&c.count, 1)
+		n = 1
+		suppressed = atomic.SwapUint64(&c.suppressed, 0)
+	} else {
+		n = atomic.AddUint64(&c.count, 1)
+	}
+
+	first := uint64(ls.sampling.First)
+	if n <= first || (ls.sampling.Thereafter > 0 && (n-first)%uint64(ls.sampling.Thereafter) == 0) {
+		return true, suppressed
+	}
+	atomic.AddUint64(&c.suppressed, 1)
+	return false, suppressed
+}
+
+func (ll *levelLimiter) check(now time.Time, msg string, fields logrus.Fields) (bool, uint64) {
+	key := msg
+	if ll.limit.Key != nil {
+		key = ll.limit.Key(msg, fields)
+	}
+
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+	b, ok := ll.buckets[key]
+	if !ok {
+		if len(ll.buckets) >= limiterMaxKeys {
+			ll.buckets = make(map[string]*tokenBucket)
+		}
+		b = &tokenBucket{tokens: float64(ll.limit.Burst), last: now}
+		ll.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * ll.limit.Rate
+	if burst := float64(ll.limit.Burst); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		b.suppressed++
+		return false, 0
+	}
+	b.tokens--
+	suppressed := b.suppressed
+	b.suppressed = 0
+	return true, suppressed
+}
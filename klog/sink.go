@@ -0,0 +1,272 @@
+package klog
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/sirupsen/logrus"
+)
+
+// Sink 日志输出目标，每个 Sink 有独立的最低级别和格式，同一条日志同时写入全部 Sink
+type Sink struct {
+	Level     Level            // 最低级别，为空时不单独限制
+	Formatter logrus.Formatter // 为空时使用 Logger 的格式
+	Writer    io.Writer        // 由调用方创建的 Writer，Logger 关闭时不会关闭
+
+	newWriter    func(o *options) (io.Writer, error) // 创建 Logger 时创建的 Writer，Logger 关闭时关闭
+	newFormatter func(o *options) logrus.Formatter
+}
+
+// LevelWriter 按级别写入的 io.Writer，例：syslog 需要按级别设置优先级
+type LevelWriter interface {
+	WriteLevel(level logrus.Level, p []byte) (int, error)
+}
+
+// ConsoleSink 彩色输出到标准输出
+func ConsoleSink(level Level) Sink {
+	return Sink{
+		Level:        level,
+		Writer:       os.Stdout,
+		newFormatter: newConsoleFormatter,
+	}
+}
+
+// FileSink 写入文件，使用 Logger 的切割策略（WithRotation 等）和异步写入设置（WithAsync）
+func FileSink(dir, filename string, level Level) Sink {
+	return Sink{
+		Level: level,
+		newWriter: func(o *options) (io.Writer, error) {
+			w, err := NewRotateWriter(dir, filename, o.rotation)
+			if err != nil {
+				return nil, err
+			}
+			if o.async != nil {
+				return NewAsyncWriter(w, *o.async), nil
+			}
+			return w, nil
+		},
+		newFormatter: newFileFormatter,
+	}
+}
+
+// SyslogSink 以 syslog 格式（RFC 3164）发送到 network 为 udp、tcp 的地址
+// 例：klog.SyslogSink("udp", "127.0.0.1:514", "sso", klog.LevelWarn)
+func SyslogSink(network, addr, tag string, level Level) Sink {
+	return Sink{
+		Level: level,
+		newWriter: func(*options) (io.Writer, error) {
+			return NewSyslogWriter(network, addr, tag)
+		},
+		newFormatter: newFileFormatter,
+	}
+}
+
+// KafkaSink 通过 sarama 生产者写入 Kafka，默认以JSON格式输出
+// producer 由调用方创建、关闭，并需读取 Errors()
+func KafkaSink(producer sarama.AsyncProducer, topic string, level Level) Sink {
+	return Sink{
+		Level:  level,
+		Writer: NewKafkaWriter(producer, topic),
+		newFormatter: func(o *options) logrus.Formatter {
+			if o.formatter != nil {
+				return o.formatter
+			}
+			return &JSONFormatter{FieldMap: o.fieldMap, CallerPath: o.callerPath}
+		},
+	}
+}
+
+// sinkHook 按 Sink 的级别和格式写入
+type sinkHook struct {
+	mu        sync.Mutex
+	levels    []logrus.Level
+	formatter logrus.Formatter
+	writer    io.Writer
+}
+
+func (h *sinkHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+func (h *sinkHook) Fire(entry *logrus.Entry) error {
+	b, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lw, ok := h.writer.(LevelWriter); ok {
+		_, err = lw.WriteLevel(entry.Level, b)
+	} else {
+		_, err = h.writer.Write(b)
+	}
+	return err
+}
+
+// newSinkHooks 创建各 Sink 的 Writer 和格式
+func newSinkHooks(o *options) (hooks []logrus.Hook, closers []io.Closer, err error) {
+	defer func() {
+		if err != nil {
+			closeAll(closers)
+		}
+	}()
+	for _, s := range o.sinks {
+		levels := logrus.AllLevels
+		if s.Level != "" {
+			lvl, err := ParseLevel(s.Level)
+			if err != nil {
+				return nil, closers, err
+			}
+			levels = logrus.AllLevels[:lvl+1]
+		}
+		w := s.Writer
+		if w == nil && s.newWriter != nil {
+			if w, err = s.newWriter(o); err != nil {
+				return nil, closers, err
+			}
+			if c, ok := w.(io.Closer); ok {
+				closers = append(closers, c)
+			}
+		}
+		if w == nil {
+			return nil, closers, fmt.Errorf("klog: sink writer is nil")
+		}
+		formatter := s.Formatter
+		if formatter == nil {
+			if s.newFormatter != nil {
+				formatter = s.newFormatter(o)
+			} else {
+				formatter = newFileFormatter(o)
+			}
+		}
+		hooks = append(hooks, &sinkHook{levels: levels, formatter: formatter, writer: w})
+	}
+	return hooks, closers, nil
+}
+
+// SyslogWriter 以 syslog 格式（RFC 3164）发送日志，facility 为 user
+type SyslogWriter struct {
+	network  string
+	addr     string
+	tag      string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+var _ LevelWriter = (*SyslogWriter)(nil)
+
+// NewSyslogWriter 连接 syslog 服务，tag 为空时使用程序名
+func NewSyslogWriter(network, addr, tag string) (*SyslogWriter, error) {
+	if tag == "" {
+		tag = os.Args[0]
+	}
+	hostname, _ := os.Hostname()
+	w := &SyslogWriter{network: network, addr: addr, tag: tag, hostname: hostname}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SyslogWriter) connect() error {
+	conn, err := net.DialTimeout(w.network, w.addr, 3*time.Second)
+	if err != nil {
+		return fmt.Errorf("klog: dial syslog %s %s: %w", w.network, w.addr, err)
+	}
+	w.conn = conn
+	return nil
+}
+
+// Write 以 info 级别写入
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(logrus.InfoLevel, p)
+}
+
+// WriteLevel 按级别设置优先级写入，连接断开时重连一次
+func (w *SyslogWriter) WriteLevel(level logrus.Level, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// 格式化后的日志以换行结尾，可直接作为 tcp 的分隔符
+	msg := fmt.Sprintf("<%d>%s %s %s[%d]: %s", 8+syslogSeverity(level), time.Now().Format(time.Stamp),
+		w.hostname, w.tag, os.Getpid(), p)
+	if w.conn != nil {
+		if _, err := w.conn.Write([]byte(msg)); err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	if err := w.connect(); err != nil {
+		return 0, err
+	}
+	if _, err := w.conn.Write([]byte(msg)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 关闭连接
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel:
+		return 1 // alert
+	case logrus.FatalLevel:
+		return 2 // crit
+	case logrus.ErrorLevel:
+		return 3 // err
+	case logrus.WarnLevel:
+		return 4 // warning
+	case logrus.InfoLevel:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+// KafkaWriter 通过 sarama 生产者写入 Kafka，每次 Write 为一条消息
+type KafkaWriter struct {
+	dropped  uint64
+	producer sarama.AsyncProducer
+	topic    string
+}
+
+// NewKafkaWriter 创建写入 topic 的 io.Writer，producer 由调用方关闭
+func NewKafkaWriter(producer sarama.AsyncProducer, topic string) *KafkaWriter {
+	return &KafkaWriter{producer: producer, topic: topic}
+}
+
+// Write 生产者队列满时丢弃并计数，不阻塞业务
+func (k *KafkaWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	select {
+	case k.producer.Input() <- &sarama.ProducerMessage{Topic: k.topic, Value: sarama.ByteEncoder(b)}:
+	default:
+		atomic.AddUint64(&k.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Dropped 生产者队列满时丢弃的条数
+func (k *KafkaWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&k.dropped)
+}
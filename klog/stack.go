@@ -0,0 +1,116 @@
+package klog
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const maxStackDepth = 64
+
+// Stack 调用栈，每个元素为 "函数 文件:行号"
+// 文本格式以多行输出在日志之后，JSON格式输出为数组
+type Stack []string
+
+// String 每个调用一行，以 tab 缩进
+func (s Stack) String() string {
+	var b strings.Builder
+	for _, frame := range s {
+		b.WriteString("\t")
+		b.WriteString(frame)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+type stackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// stacktrace 按级别采集调用栈
+type stacktrace struct {
+	levels       [logrus.TraceLevel + 1]bool
+	skipPackages []string
+}
+
+func newStacktrace(o *options) (*stacktrace, error) {
+	if len(o.stackLevels) == 0 {
+		return nil, nil
+	}
+	st := &stacktrace{skipPackages: append(append([]string{"runtime"}, DefaultSkipPackages...), o.skipPackages...)}
+	for _, level := range o.stackLevels {
+		lvl, err := ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		st.levels[lvl] = true
+	}
+	return st, nil
+}
+
+// stack 优先使用 keyVals 中 error 携带的调用栈（pkg/errors，kratos errors 的 cause），否则采集当前调用栈
+func (st *stacktrace) stack(level logrus.Level, keyVals []interface{}) Stack {
+	if st == nil || int(level) >= len(st.levels) || !st.levels[level] {
+		return nil
+	}
+	for _, v := range keyVals {
+		err, ok := v.(error)
+		for ok && err != nil {
+			if tracer, is := err.(stackTracer); is {
+				trace := tracer.StackTrace()
+				pcs := make([]uintptr, len(trace))
+				for i, f := range trace {
+					pcs[i] = uintptr(f)
+				}
+				return st.format(pcs)
+			}
+			err = errors.Unwrap(err)
+		}
+	}
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	return st.format(pcs[:n])
+}
+
+// format 过滤 runtime 及日志相关包的调用
+func (st *stacktrace) format(pcs []uintptr) Stack {
+	stack := make(Stack, 0, len(pcs))
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" && !inPackages(frame, st.skipPackages) {
+			stack = append(stack, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		}
+		if !more {
+			return stack
+		}
+	}
+}
+
+// stackFormatter 将调用栈从字段中取出，以多行的形式输出在日志之后
+type stackFormatter struct {
+	logrus.Formatter
+}
+
+func (f *stackFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	stack, ok := entry.Data[FieldKeyStack].(Stack)
+	if !ok {
+		return f.Formatter.Format(entry)
+	}
+	e := *entry
+	e.Data = make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		if k != FieldKeyStack {
+			e.Data[k] = v
+		}
+	}
+	b, err := f.Formatter.Format(&e)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, stack.String()...), nil
+}
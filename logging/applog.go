@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"os"
+	"sync/atomic"
+
+	"github.com/darrenyjq/kratos-middleware/klog"
+
+	"github.com/Shopify/sarama"
+	"github.com/sirupsen/logrus"
+)
+
+var appLogDropped uint64
+
+type appLogMessage struct {
+	topic string
+	value []byte
+}
+
+// AppLogSink 应用日志写入 Kafka 的 klog 输出目标，使用 InitKafka 创建的生产者和协程池
+// 日志以JSON格式输出，包含 service、host、prefix、trace_id、span_id 字段
+// 例：klog.New(klog.WithSinks(klog.ConsoleSink(klog.LevelDebug), logging.AppLogSink("app-log", "sso", klog.LevelInfo)))
+func AppLogSink(topic, service string, level klog.Level) klog.Sink {
+	host, _ := os.Hostname()
+	return klog.Sink{
+		Level:     level,
+		Formatter: &appLogFormatter{service: service, host: host},
+		Writer:    &appLogWriter{topic: topic},
+	}
+}
+
+// AppLogDropped 协程池已满或未初始化 Kafka 时丢弃的应用日志条数
+func AppLogDropped() uint64 {
+	return atomic.LoadUint64(&appLogDropped)
+}
+
+type appLogFormatter struct {
+	klog.JSONFormatter
+	service string
+	host    string
+}
+
+func (f *appLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	e := *entry
+	e.Data = make(logrus.Fields, len(entry.Data)+2)
+	for k, v := range entry.Data {
+		e.Data[k] = v
+	}
+	e.Data["service"] = f.service
+	e.Data["host"] = f.host
+	return f.JSONFormatter.Format(&e)
+}
+
+type appLogWriter struct {
+	topic string
+}
+
+// Write 协程池已满时丢弃并计数，不阻塞业务
+func (w *appLogWriter) Write(p []byte) (int, error) {
+	pool := AppLogWorkerPool
+	if pool == nil || asyncProducer == nil || pool.Free() == 0 {
+		atomic.AddUint64(&appLogDropped, 1)
+		return len(p), nil
+	}
+	b := make([]byte, len(p))
+	copy(b, p)
+	if err := pool.Invoke(&appLogMessage{topic: w.topic, value: b}); err != nil {
+		atomic.AddUint64(&appLogDropped, 1)
+	}
+	return len(p), nil
+}
+
+func appLog(msg *appLogMessage) {
+	if asyncProducer == nil {
+		return
+	}
+	asyncProducer.Input() <- &sarama.ProducerMessage{
+		Topic: msg.topic,
+		Value: sarama.ByteEncoder(msg.value),
+	}
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/darrenyjq/kratos-middleware/klog"
+
+	"github.com/Shopify/sarama/mocks"
+	"github.com/panjf2000/ants"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAppLogFormatter(t *testing.T) {
+	sink := AppLogSink("app-log", "sso", klog.LevelInfo)
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		klog.FieldKeyPrefix:  "sso",
+		klog.FieldKeyTraceID: "abc",
+		klog.FieldKeySpanID:  "def",
+	})
+	entry.Time = time.Now()
+	entry.Level = logrus.WarnLevel
+	entry.Message = "hello"
+	b, err := sink.Formatter.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	for k, v := range map[string]interface{}{"service": "sso", "host": host, "prefix": "sso", "trace_id": "abc", "span_id": "def", "level": "warning"} {
+		if data[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, data[k])
+		}
+	}
+	if _, ok := entry.Data["service"]; ok {
+		t.Error("expected the entry to be unchanged")
+	}
+}
+
+func TestAppLogWriterDrop(t *testing.T) {
+	pool, producer := AppLogWorkerPool, asyncProducer
+	defer func() { AppLogWorkerPool, asyncProducer = pool, producer }()
+
+	w := &appLogWriter{topic: "app-log"}
+	AppLogWorkerPool, asyncProducer = nil, nil
+	dropped := AppLogDropped()
+	if n, err := w.Write([]byte("no kafka")); n != 8 || err != nil || AppLogDropped() != dropped+1 {
+		t.Fatalf("expected drop without kafka, got %d %v %d", n, err, AppLogDropped()-dropped)
+	}
+
+	mock := mocks.NewAsyncProducer(t, nil)
+	defer mock.Close()
+	asyncProducer = mock
+	block, received := make(chan struct{}), make(chan *appLogMessage, 1)
+	AppLogWorkerPool, _ = ants.NewPoolWithFunc(1, func(i interface{}) {
+		received <- i.(*appLogMessage)
+		<-block
+	})
+	defer AppLogWorkerPool.Release()
+	w.Write([]byte("first"))
+	if msg := <-received; msg.topic != "app-log" || string(msg.value) != "first" {
+		t.Fatalf("unexpected message %s %s", msg.topic, msg.value)
+	}
+	// 协程池已满时丢弃，不阻塞
+	dropped = AppLogDropped()
+	w.Write([]byte("second"))
+	close(block)
+	if AppLogDropped() != dropped+1 {
+		t.Errorf("expected 1 dropped, got %d", AppLogDropped()-dropped)
+	}
+}
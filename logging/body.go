@@ -0,0 +1,217 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	nethttp "net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/darrenyjq/kratos-middleware/redact"
+
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+const defaultMaxBodySize = 4 << 10
+
+// BodyCapture 原始请求体、响应体的记录方式
+type BodyCapture struct {
+	MaxSize int // 最多记录的字节数，默认 4KB，超过时截断
+}
+
+// CaptureBody 记录原始的请求体和编码后的响应体，替代 Logger 中按解码后的请求、响应序列化的内容
+// 表单、格式错误的JSON、文件上传等均按实际内容记录，二进制内容记录为 [binary N bytes]
+// 例：http.NewServer(http.Filter(logging.CaptureBody(logging.BodyCapture{MaxSize: 8 << 10})))
+func CaptureBody(capture BodyCapture) http.FilterFunc {
+	if capture.MaxSize <= 0 {
+		capture.MaxSize = defaultMaxBodySize
+	}
+	return func(next nethttp.Handler) nethttp.Handler {
+		return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			rec := &bodyRecorder{
+				req:  limitedBuffer{max: capture.MaxSize},
+				resp: limitedBuffer{max: capture.MaxSize},
+			}
+			if r.Body != nil && r.Body != nethttp.NoBody {
+				r.Body = &teeReadCloser{Reader: io.TeeReader(r.Body, &rec.req), Closer: r.Body}
+			}
+			r = r.WithContext(context.WithValue(r.Context(), bodyRecorderKey{}, rec))
+			next.ServeHTTP(&recordResponseWriter{ResponseWriter: w, rec: rec}, r)
+			rec.done()
+		})
+	}
+}
+
+type bodyRecorderKey struct{}
+
+// bodyRecorder 一次请求记录的原始内容，响应写完后执行 Logger 注册的回调
+type bodyRecorder struct {
+	mu     sync.Mutex
+	req    limitedBuffer
+	resp   limitedBuffer
+	status int
+	onDone func()
+}
+
+func bodyRecorderFromRequest(r *nethttp.Request) (*bodyRecorder, bool) {
+	rec, ok := r.Context().Value(bodyRecorderKey{}).(*bodyRecorder)
+	return rec, ok
+}
+
+// setOnDone 设置响应写完后的回调
+func (rec *bodyRecorder) setOnDone(fn func()) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.onDone = fn
+}
+
+func (rec *bodyRecorder) done() {
+	rec.mu.Lock()
+	fn := rec.onDone
+	rec.onDone = nil
+	rec.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// statusCode 实际写入的状态码，未调用 WriteHeader 时为 200
+func (rec *bodyRecorder) statusCode() int {
+	if rec.status == 0 {
+		return nethttp.StatusOK
+	}
+	return rec.status
+}
+
+// limitedBuffer 最多保存 max 字节，记录总字节数
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	max   int
+	total int
+}
+
+// Write 超过 max 的部分只计数，返回完整的长度
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += n
+	if room := b.max - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		b.buf.Write(p)
+	}
+	return n, nil
+}
+
+// String 按 Content-Type 和内容判断是否为二进制，超过最大字节数时截断并标明总字节数
+func (b *limitedBuffer) String(contentType string) string {
+	return b.redacted(nil, contentType)
+}
+
+// redacted 同 String，文本内容按 Content-Type 脱敏，表单按字段名脱敏，其余按 Redactor.Raw 处理，保持原始内容
+func (b *limitedBuffer) redacted(r *redact.Redactor, contentType string) string {
+	if b.total == 0 {
+		return ""
+	}
+	data := b.buf.Bytes()
+	truncated := b.total > len(data)
+	if truncated {
+		// 去掉截断后不完整的字符
+		for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if isBinary(contentType, data) {
+		return fmt.Sprintf("[binary %d bytes]", b.total)
+	}
+	text := string(data)
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+		text = r.Form(text)
+	} else {
+		text = r.Raw(text)
+	}
+	if truncated {
+		return fmt.Sprintf("%s...[truncated, %d bytes]", text, b.total)
+	}
+	return text
+}
+
+// isBinary 文本类型的 Content-Type 按文本处理，其余按内容判断：非 UTF-8 或包含控制字符
+func isBinary(contentType string, data []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch {
+		case strings.HasPrefix(mediaType, "text/"),
+			strings.HasSuffix(mediaType, "json"),
+			strings.HasSuffix(mediaType, "xml"),
+			mediaType == "application/x-www-form-urlencoded",
+			mediaType == "application/javascript":
+			return false
+		case strings.HasPrefix(mediaType, "image/"),
+			strings.HasPrefix(mediaType, "audio/"),
+			strings.HasPrefix(mediaType, "video/"),
+			mediaType == "application/octet-stream",
+			mediaType == "application/x-protobuf",
+			mediaType == "application/proto":
+			return true
+		}
+	}
+	if !utf8.Valid(data) {
+		return true
+	}
+	for _, c := range data {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			return true
+		}
+	}
+	return false
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// recordResponseWriter 记录状态码和写入的响应体
+type recordResponseWriter struct {
+	nethttp.ResponseWriter
+	rec *bodyRecorder
+}
+
+func (w *recordResponseWriter) WriteHeader(statusCode int) {
+	if w.rec.status == 0 {
+		w.rec.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordResponseWriter) Write(p []byte) (int, error) {
+	if w.rec.status == 0 {
+		w.rec.status = nethttp.StatusOK
+	}
+	w.rec.resp.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Hijack 支持 websocket 等协议升级，之后的内容不再记录
+func (w *recordResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(nethttp.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("logging: %T does not implement http.Hijacker", w.ResponseWriter)
+	}
+	if w.rec.status == 0 {
+		w.rec.status = nethttp.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+func (w *recordResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(nethttp.Flusher); ok {
+		f.Flush()
+	}
+}
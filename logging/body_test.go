@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"context"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/darrenyjq/kratos-middleware/redact"
+
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+func TestCaptureBody(t *testing.T) {
+	accesses := make(chanRequestLogger, 1)
+	srv := http.NewServer(
+		http.Filter(CaptureBody(BodyCapture{MaxSize: 16})),
+		http.Middleware(Logger(Options{RequestLogger: accesses})),
+	)
+	srv.Route("/").POST("/echo", func(ctx http.Context) error {
+		var in map[string]interface{}
+		if err := ctx.Bind(&in); err != nil {
+			return err
+		}
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return map[string]string{"message": "hello"}, nil
+		})
+		out, err := h(ctx, &in)
+		if err != nil {
+			return err
+		}
+		return ctx.Result(nethttp.StatusCreated, out)
+	})
+
+	r := httptest.NewRequest(nethttp.MethodPost, "/echo", strings.NewReader(`{"name":"abcdefghijklmnopqrstuvwxyz"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+
+	access := <-accesses
+	if access.Request.Body != `{"name":"abcdefg...[truncated, 37 bytes]` {
+		t.Errorf("unexpected request body: %s", access.Request.Body)
+	}
+	if access.Response.Status != nethttp.StatusCreated || access.Response.Body != `{"message":"hell...[truncated, 19 bytes]` ||
+		!strings.HasPrefix(access.Response.Header["Content-Type"], "application/json") {
+		t.Errorf("unexpected response: %+v", access.Response)
+	}
+}
+
+func TestCaptureBodyRedact(t *testing.T) {
+	accesses := make(chanRequestLogger, 1)
+	srv := http.NewServer(
+		http.Filter(CaptureBody(BodyCapture{MaxSize: 50})),
+		http.Middleware(Logger(Options{RequestLogger: accesses, Redactor: redact.Default()})),
+	)
+	srv.Route("/").POST("/login", func(ctx http.Context) error {
+		io.Copy(io.Discard, ctx.Request().Body)
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return map[string]string{"access_token": "abcdefghijklmnopqrstuvwxyz0123456789"}, nil
+		})
+		out, err := h(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return ctx.Result(nethttp.StatusOK, out)
+	})
+
+	r := httptest.NewRequest(nethttp.MethodPost, "/login", strings.NewReader("username=bob&password=hunter2&access_token=abc"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+
+	access := <-accesses
+	if access.Request.Body != "username=bob&password=******&access_token=******" {
+		t.Errorf("unexpected request body: %s", access.Request.Body)
+	}
+	// 截断的 JSON 仍按字段名脱敏
+	if access.Response.Body != `{"access_token":"******"...[truncated, 55 bytes]` {
+		t.Errorf("unexpected response body: %s", access.Response.Body)
+	}
+}
+
+func TestCaptureBodyKeepsRaw(t *testing.T) {
+	b := limitedBuffer{max: 64}
+	b.Write([]byte(`{ "b": 1.50, "password": "hunter2", "a": 1 }`))
+	if got := b.redacted(redact.Default(), "application/json"); got != `{ "b": 1.50, "password": "******", "a": 1 }` {
+		t.Errorf("expected the raw body with masked values, got %s", got)
+	}
+}
+
+func TestCaptureBodyHijack(t *testing.T) {
+	ts := httptest.NewServer(CaptureBody(BodyCapture{})(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		h, ok := w.(nethttp.Hijacker)
+		if !ok {
+			t.Error("expected http.Hijacker")
+			return
+		}
+		conn, buf, err := h.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+	})))
+	defer ts.Close()
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, ts.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	resp, err := nethttp.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusSwitchingProtocols {
+		t.Errorf("expected 101, got %d", resp.StatusCode)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	tests := []struct {
+		data        string
+		contentType string
+		want        string
+	}{
+		{"a=1&b=2", "application/x-www-form-urlencoded", "a=1&b=2"},
+		{"\x89PNG\r\n\x1a\n", "", "[binary 8 bytes]"},
+		{"hello", "image/png", "[binary 5 bytes]"},
+		{"中文中文中文", "text/plain", "中文...[truncated, 18 bytes]"},
+	}
+	for _, tt := range tests {
+		b := limitedBuffer{max: 8}
+		if n, err := b.Write([]byte(tt.data)); n != len(tt.data) || err != nil {
+			t.Errorf("%q: expected %d bytes written, got %d %v", tt.data, len(tt.data), n, err)
+		}
+		if got := b.String(tt.contentType); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.data, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"google.golang.org/grpc/metadata"
+)
+
+type attemptKey struct{}
+
+// WithRetry 在重试循环外调用，之后每次调用记录为同一次调用的第几次尝试
+// 例：ctx = logging.WithRetry(ctx); for i := 0; i < 3; i++ { reply, err = client.GetUser(ctx, req) }
+func WithRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptKey{}, new(int32))
+}
+
+// Client 客户端访问日志中间件，记录调用下游 HTTP、gRPC 接口的请求和响应，direction 为 outbound
+// 写入与 Logger 相同的 RequestLogger，parent_request_id 为所在服务端请求的请求ID
+// 每次调用生成新的请求ID，通过 RequestID.Header 传递给下游，下游的 Logger 使用同一个请求ID
+func Client(options ...Options) middleware.Middleware {
+	a := newAccessLogger(prepareOptions(options))
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (reply interface{}, err error) {
+			start := time.Now()
+			tr, ok := transport.FromClientContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+			var (
+				access *Access
+				info   requestInfo
+				header nethttp.Header // 用于 HideRequestBodyFunc
+			)
+			switch t := tr.(type) {
+			case *http.Transport:
+				access, info, header = newHTTPClientAccess(t.Request(), tr.Operation())
+			case *grpc.Transport:
+				access, info, header = newGRPCClientAccess(ctx, tr)
+			default:
+				return handler(ctx, req)
+			}
+			if a.skip.match(info) {
+				return handler(ctx, req)
+			}
+			access.Direction = DirectionOutbound
+			access.Endpoint = tr.Endpoint()
+			// 不读取请求头，tracing.Client 设置的 traceparent 在同一链路中相同
+			access.RequestID = a.requestID.Generator(ctx)
+			a.requestID.setHeader(tr.RequestHeader(), access.RequestID)
+			access.ParentRequestID, _ = RequestIDFromContext(ctx)
+			access.Attempt = 1
+			if attempts, ok := ctx.Value(attemptKey{}).(*int32); ok {
+				access.Attempt = int(atomic.AddInt32(attempts, 1))
+			}
+			omit := a.omitBody.match(info)
+			access.Request.Body = a.requestBody(req, header, omit)
+
+			reply, err = handler(ctx, req)
+
+			a.finish(access, start, req, reply, err, omit, tr.ReplyHeader())
+			a.log(access)
+			return
+		}
+	}
+}
+
+// newHTTPClientAccess 由发出的 HTTP 请求创建访问记录，不含请求体
+func newHTTPClientAccess(req *nethttp.Request, operation string) (*Access, requestInfo, nethttp.Header) {
+	info := requestInfo{
+		path:        req.URL.Path,
+		operation:   operation,
+		method:      req.Method,
+		contentType: req.Header.Get("Content-Type"),
+	}
+	return &Access{
+		Protocol: ProtocolHTTP,
+		Request: Request{
+			Method:    req.Method,
+			Path:      req.URL.Path,
+			URI:       req.URL.String(),
+			Operation: operation,
+			Header:    toMapString(req.Header),
+		},
+	}, info, req.Header
+}
+
+// newGRPCClientAccess 由发出的 gRPC 请求创建访问记录，metadata 包含 outgoing context 和 Transport 中的请求头
+func newGRPCClientAccess(ctx context.Context, tr transport.Transporter) (*Access, requestInfo, nethttp.Header) {
+	header := make(nethttp.Header)
+	mdMap := make(map[string]string)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for k, v := range md {
+		header[textproto.CanonicalMIMEHeaderKey(k)] = v
+		mdMap[k] = strings.Join(v, " ")
+	}
+	for _, k := range tr.RequestHeader().Keys() {
+		v := tr.RequestHeader().Get(k)
+		header.Set(k, v)
+		mdMap[k] = v
+	}
+	operation := tr.Operation()
+	info := requestInfo{
+		path:        operation,
+		operation:   operation,
+		contentType: header.Get("Content-Type"),
+	}
+	return &Access{
+		Protocol: ProtocolGRPC,
+		Request: Request{
+			Path:      operation,
+			URI:       operation,
+			Operation: operation,
+			Header:    mdMap,
+		},
+	}, info, header
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type chanRequestLogger chan *Access
+
+func (c chanRequestLogger) Log(access *Access) {
+	c <- access
+}
+
+func TestClient(t *testing.T) {
+	accesses := make(chanRequestLogger, 2)
+	// Transport 没有请求头，不传递请求ID
+	m := Client(Options{RequestLogger: accesses, RequestID: RequestIDOptions{Header: "-"}})
+	handler := m(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.ServiceUnavailable("UNAVAILABLE", "try again")
+	})
+
+	ctx := NewRequestIDContext(context.Background(), "inbound-1")
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-device-id", "device-1",
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx = transport.NewClientContext(WithRetry(ctx), &grpc.Transport{})
+	for i := 0; i < 2; i++ {
+		handler(ctx, map[string]string{"id": "1"})
+	}
+	attempts := 0
+	requestIDs := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		access := <-accesses
+		// 每次调用生成新的请求ID，不使用 traceparent
+		if access.RequestID == "" || access.RequestID == "4bf92f3577b34da6a3ce929d0e0e4736" || requestIDs[access.RequestID] {
+			t.Fatalf("expected a new request id, got %q", access.RequestID)
+		}
+		requestIDs[access.RequestID] = true
+		if access.Direction != DirectionOutbound || access.Protocol != ProtocolGRPC || access.ParentRequestID != "inbound-1" ||
+			access.Request.Header["x-device-id"] != "device-1" || access.Request.Body != `{"id":"1"}` {
+			t.Fatalf("unexpected access: %+v", access)
+		}
+		if access.Response.Status != 503 || access.Response.GRPCCode != "Unavailable" || access.Error.Reason != "UNAVAILABLE" {
+			t.Fatalf("unexpected response: %+v %+v", access.Response, access.Error)
+		}
+		attempts |= 1 << access.Attempt
+	}
+	// RequestLogger 异步写入，顺序不确定
+	if attempts != 1<<1|1<<2 {
+		t.Fatalf("expected attempt 1 and 2, got %b", attempts)
+	}
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"context"
+	"net"
+	nethttp "net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/darrenyjq/kratos-middleware/logging/usertrack"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// newGRPCAccess 由 gRPC 请求创建访问记录，不含请求体和请求ID
+// header 为转换成 HTTP 格式的 metadata，供 HideRequestBodyFunc 使用
+func newGRPCAccess(ctx context.Context, operation string) (*Access, requestInfo, nethttp.Header) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := make(nethttp.Header, len(md))
+	mdMap := make(map[string]string, len(md))
+	for k, v := range md {
+		header[textproto.CanonicalMIMEHeaderKey(k)] = v
+		mdMap[k] = strings.Join(v, " ")
+	}
+	info := requestInfo{
+		path:        operation,
+		operation:   operation,
+		contentType: header.Get("Content-Type"),
+	}
+
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	requestFeatures := prepareMetadataFeatureMap(header, remoteAddr)
+	userTrack := usertrack.Parse(requestFeatures)
+	if remoteAddr != "" {
+		userTrack.RemoteAddr = remoteAddr
+	}
+	userTrack.AccessToken = header.Get("X-Access-Token")
+
+	return &Access{
+		Protocol: ProtocolGRPC,
+		Request: Request{
+			Path:      operation,
+			URI:       operation,
+			Operation: operation,
+			Header:    mdMap,
+		},
+		UserTrackFeature: userTrack,
+	}, info, header
+}
+
+// prepareMetadataFeatureMap 同 prepareRequestFeatureMap，客户端 IP 优先使用代理设置的请求头，否则使用 peer 地址
+func prepareMetadataFeatureMap(header nethttp.Header, remoteAddr string) map[string]interface{} {
+	features := make(map[string]interface{})
+	for _, key := range []string{"X-Original-Forwarded-For", "X-Forwarded-For", "X-Real-Ip"} {
+		if ip := getFirstIP(header.Get(key)); ip != "" {
+			features["_client_ip"] = ip
+			break
+		}
+	}
+	if _, ok := features["_client_ip"]; !ok {
+		if ip, _, err := net.SplitHostPort(remoteAddr); err == nil {
+			features["_client_ip"] = ip
+		}
+	}
+	for _, headerName := range featureHeaders {
+		if h := header.Get(headerName); h != "" {
+			features[headerName] = h
+		}
+	}
+	return features
+}
+
+func getFirstIP(s string) string {
+	if index := strings.IndexByte(s, ','); index >= 0 {
+		s = s[:index]
+	}
+	return strings.TrimSpace(s)
+}
+
+// messageSize protobuf 消息的字节数，不是 protobuf 消息时为 0
+func messageSize(v interface{}) int {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return 0
+}
+
+// grpcCode gRPC 的状态码，kratos 错误按其 GRPCStatus 转换
+func grpcCode(err error) string {
+	if err == nil {
+		return codes.OK.String()
+	}
+	return errors.FromError(err).GRPCStatus().Code().String()
+}
@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestGRPCAccess(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"x-device-id", "device-1", "content-type", "application/grpc"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	access, info, header := newGRPCAccess(ctx, "/api.user.v1.User/Get")
+	if access.Protocol != ProtocolGRPC || access.Request.Operation != "/api.user.v1.User/Get" ||
+		access.Request.Header["x-device-id"] != "device-1" {
+		t.Fatalf("unexpected access: %+v", access)
+	}
+	if access.UserTrackFeature.IpAddr != "10.0.0.1" || access.UserTrackFeature.RemoteAddr != "10.0.0.1:5000" ||
+		access.UserTrackFeature.DeviceID != "device-1" {
+		t.Fatalf("unexpected user track: %+v", access.UserTrackFeature)
+	}
+	if info.path != "/api.user.v1.User/Get" || info.contentType != "application/grpc" || header.Get("X-Device-Id") != "device-1" {
+		t.Fatalf("unexpected request info: %+v", info)
+	}
+	if code := grpcCode(errors.NotFound("USER_NOT_FOUND", "user not found")); code != "NotFound" {
+		t.Errorf("expected NotFound, got %s", code)
+	}
+	if code := grpcCode(nil); code != "OK" {
+		t.Errorf("expected OK, got %s", code)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"strings"
 	"time"
 
-	"kratos-middleware/logging/usertrack"
+	"github.com/darrenyjq/kratos-middleware/logging/usertrack"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
 )
 
 type UserTracker interface {
@@ -49,21 +52,44 @@ type RequestLogger interface {
 }
 
 type Request struct {
-	Method string            `json:"method"`
-	Path   string            `json:"path"`
-	URI    string            `json:"uri"`
-	Header map[string]string `json:"header"`
-	Body   string            `json:"body"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	URI       string            `json:"uri"`
+	Operation string            `json:"operation,omitempty"` // kratos 的 Operation，gRPC 为完整方法名
+	Header    map[string]string `json:"header"`              // gRPC 为请求的 metadata
+	Body      string            `json:"body"`
+	Size      int               `json:"size,omitempty"` // gRPC 消息的字节数
 }
 
 type Response struct {
-	Status int               `json:"status"`
-	Header map[string]string `json:"header"`
-	Body   string            `json:"body"`
+	Status   int               `json:"status"`
+	GRPCCode string            `json:"grpc_code,omitempty"` // gRPC 的状态码 例：OK、NotFound
+	Header   map[string]string `json:"header"`
+	Body     string            `json:"body"`
+	Size     int               `json:"size,omitempty"` // gRPC 消息的字节数
 }
 
+// Protocol 访问的协议
+type Protocol = string
+
+const (
+	ProtocolHTTP Protocol = "http"
+	ProtocolGRPC Protocol = "grpc"
+)
+
+// Direction 访问的方向
+type Direction = string
+
+const (
+	DirectionInbound  Direction = "inbound"  // 服务端收到的请求
+	DirectionOutbound Direction = "outbound" // 客户端发出的请求
+)
+
 type Access struct {
-	Time time.Time `json:"time"`
+	Time      time.Time `json:"time"`
+	Protocol  Protocol  `json:"protocol"`
+	Direction Direction `json:"direction"`
+	Endpoint  string    `json:"endpoint,omitempty"` // 客户端调用的目标地址
 
 	ServerID   string   `json:"server_id"`
 	ServerPort string   `json:"server_port"`
@@ -71,12 +97,51 @@ type Access struct {
 	Request    Request  `json:"request"`
 	Response   Response `json:"response"`
 
+	ParentRequestID string `json:"parent_request_id,omitempty"` // 客户端调用时所在的服务端请求ID
+	Attempt         int    `json:"attempt,omitempty"`           // 客户端调用的第几次尝试，见 WithRetry
+
+	Error *Error `json:"error,omitempty"` // 处理出错时的错误信息
+
 	Latency   string `json:"latency"`
 	LatencyNs int64  `json:"latency_ns"`
 
 	UserTrackFeature usertrack.Feature `json:"user_track_feature"`
 }
 
+// Error 处理出错时的错误信息，由 kratos errors.FromError 得到
+type Error struct {
+	Code     int               `json:"code"`
+	Reason   string            `json:"reason"`
+	Message  string            `json:"message"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// newError 转换为 kratos 的错误，非 kratos 错误的状态码为 500
+func newError(err error) *Error {
+	se := errors.FromError(err)
+	if se == nil {
+		return nil
+	}
+	return &Error{
+		Code:     int(se.Code),
+		Reason:   se.Reason,
+		Message:  se.Message,
+		Metadata: se.Metadata,
+	}
+}
+
+// headerToMap 转换 kratos 的请求头或响应头
+func headerToMap(header transport.Header) map[string]string {
+	ret := make(map[string]string)
+	if header == nil {
+		return ret
+	}
+	for _, key := range header.Keys() {
+		ret[key] = header.Get(key)
+	}
+	return ret
+}
+
 func toMapString(bean http.Header) (ret map[string]string) {
 	ret = make(map[string]string)
 	for key, value := range bean {
@@ -19,6 +19,7 @@ var (
 var (
 	asyncProducer        sarama.AsyncProducer
 	HttpAccessWorkerPool *ants.PoolWithFunc
+	AppLogWorkerPool     *ants.PoolWithFunc // 应用日志，见 AppLogSink
 )
 
 func InitKafka(brokerList []string, logger log.Logger) {
@@ -29,6 +30,12 @@ func InitKafka(brokerList []string, logger log.Logger) {
 			httpAccess(acc)
 		}
 	})
+	AppLogWorkerPool, _ = ants.NewPoolWithFunc(60, func(i interface{}) {
+		msg, is := i.(*appLogMessage)
+		if is {
+			appLog(msg)
+		}
+	})
 
 }
 
@@ -8,25 +8,42 @@ import (
 	"strings"
 	"time"
 
-	"kratos-middleware/logging/usertrack"
-	"kratos-middleware/util"
+	"github.com/darrenyjq/kratos-middleware/klog"
+	"github.com/darrenyjq/kratos-middleware/logging/usertrack"
+	"github.com/darrenyjq/kratos-middleware/redact"
+	"github.com/darrenyjq/kratos-middleware/util"
 
 	"github.com/Shopify/sarama"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/middleware"
 	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
 	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/sirupsen/logrus"
 )
 
 type Options struct {
 	Debug bool
 
-	IgnorePrefix       string
-	IgnoreContentTypes []string
+	IgnorePrefix       string   // 不记录的路径前缀，同 Skip.Prefixes
+	IgnoreContentTypes []string // 不记录请求体和响应体的 Content-Type，同 OmitBody.ContentTypes
+
+	// Skip 匹配的请求不记录
+	Skip Matcher
+	// OmitBody 匹配的请求只记录请求行、请求头等，请求体和响应体记录为 [ignored]
+	// 例：文件上传 OmitBody: logging.Matcher{ContentTypes: []string{"multipart/form-data"}}
+	OmitBody Matcher
 
 	HideRequestBodyFunc func(nethttp.Header) bool
 	RequestLogger       RequestLogger
 	Logger              log.Logger
+
+	// Redactor 请求头、请求体和响应体脱敏，例：redact.Default()
+	Redactor *redact.Redactor
+
+	// RequestID 请求ID的读取、生成和返回方式，默认读取 X-Request-Id、traceparent、trace.id，没有时生成 UUID
+	// 例：RequestID: logging.RequestIDOptions{Generator: logging.Snowflake(1)}
+	RequestID RequestIDOptions
 }
 
 func prepareOptions(opts []Options) Options {
@@ -37,111 +54,180 @@ func prepareOptions(opts []Options) Options {
 	return opt
 }
 
+// Logger 服务端访问日志中间件，记录 HTTP、gRPC 请求，direction 为 inbound
+// 响应头在中间件返回时记录，不含之后由编码器设置的 Content-Type 等，需要时配合 CaptureBody 在响应写完后记录
 func Logger(options ...Options) middleware.Middleware {
-	opt := prepareOptions(options)
+	a := newAccessLogger(prepareOptions(options))
 	return func(handler middleware.Handler) middleware.Handler {
 		return func(ctx context.Context, req interface{}) (reply interface{}, err error) {
+			start := time.Now()
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
 			var (
-				stdreq *nethttp.Request
-				start  = time.Now()
+				access *Access
+				info   requestInfo
+				header nethttp.Header // 用于 HideRequestBodyFunc
+				rec    *bodyRecorder  // 由 CaptureBody 记录的原始内容
 			)
-			if tr, ok := transport.FromServerContext(ctx); ok {
-				// 断言成HTTP的Transport可以拿到特殊信息
-				if ht, ok := tr.(*http.Transport); ok {
-					stdreq = ht.Request()
-				}
-			}
-			if stdreq == nil ||
-				len(opt.IgnorePrefix) > 0 && strings.HasPrefix(stdreq.URL.Path, opt.IgnorePrefix) {
+			// 断言成HTTP、gRPC的Transport可以拿到特殊信息
+			switch t := tr.(type) {
+			case *http.Transport:
+				access, info = newHTTPAccess(t.Request(), tr.Operation())
+				header = t.Request().Header
+				rec, _ = bodyRecorderFromRequest(t.Request())
+			case *grpc.Transport:
+				access, info, header = newGRPCAccess(ctx, tr.Operation())
+			default:
 				return handler(ctx, req)
 			}
-			requestID := stdreq.Header.Get("trace.id")
-			if requestID == "" {
-				requestID = fmt.Sprintf("%d", time.Now().UnixNano())
-			}
-			requestFeatures := prepareRequestFeatureMap(stdreq)
-			requestFeatures["_request_id"] = requestID
-			userTrack := usertrack.Parse(requestFeatures)
-			userTrack.AccessToken = stdreq.URL.Query().Get("access_token")
-			if userTrack.AccessToken == "" {
-				userTrack.AccessToken = stdreq.Header.Get("")
-			}
-			cookieSid, _ := stdreq.Cookie("SESSIONID")
-			if cookieSid == nil {
-				cookieSid, _ = stdreq.Cookie("com.zto.sessionId")
+			if a.skip.match(info) {
+				return handler(ctx, req)
 			}
-			if cookieSid != nil {
-				userTrack.SessionID = cookieSid.Value
+			access.Direction = DirectionInbound
+			access.RequestID = a.requestID.resolve(ctx, header)
+			access.UserTrackFeature.HttpRequestID = access.RequestID
+			a.requestID.setHeader(tr.ReplyHeader(), access.RequestID)
+			omit := a.omitBody.match(info)
+			access.Request.Body = a.requestBody(req, header, omit)
+
+			reply, err = handler(NewRequestIDContext(ctx, access.RequestID), req)
+
+			a.finish(access, start, req, reply, err, omit, tr.ReplyHeader())
+			if rec == nil {
+				a.log(access)
+				return
 			}
-
-			var requestBody string
-			var ignoreBody = false
-			if opt.HideRequestBodyFunc != nil {
-				if opt.HideRequestBodyFunc(stdreq.Header) {
-					ignoreBody = true
+			// 响应写完后使用原始内容和实际的状态码
+			rec.setOnDone(func() {
+				access.Response.Status = rec.statusCode()
+				access.Response.Header = a.opt.Redactor.Header(headerToMap(tr.ReplyHeader()))
+				if !omit && !(a.opt.HideRequestBodyFunc != nil && a.opt.HideRequestBodyFunc(header)) {
+					access.Request.Body = rec.req.redacted(a.opt.Redactor, header.Get("Content-Type"))
 				}
-			}
+				if !omit {
+					access.Response.Body = rec.resp.redacted(a.opt.Redactor, tr.ReplyHeader().Get("Content-Type"))
+				}
+				a.log(access)
+			})
+			return
+		}
+	}
+}
 
-			if !ignoreBody {
-				requestBody = util.ToJson(req)
-			} else {
-				requestBody = "[ignored]"
-			}
+// accessLogger 服务端和客户端中间件共用
+type accessLogger struct {
+	opt       Options
+	skip      *matcher
+	omitBody  *matcher
+	requestID RequestIDOptions
+}
 
-			reply, err = handler(ctx, req)
-
-			// Stop timer
-			latency := time.Now().Sub(start)
-
-			httpAccess := Access{
-				ServerID:   serverID,
-				ServerPort: serverPort,
-				Time:       start,
-				RequestID:  requestID,
-				Request: Request{
-					Method: stdreq.Method,
-					Path:   stdreq.URL.Path,
-					URI:    stdreq.URL.String(),
-					Header: toMapString(stdreq.Header),
-					Body:   requestBody,
-				},
-				Response: Response{
-					Status: nethttp.StatusOK,
-					Header: toMapString(nil),
-					Body:   util.ToJson(reply),
-				},
-				Latency:   latency.String(),
-				LatencyNs: int64(latency),
-
-				UserTrackFeature: userTrack,
-			}
-			if err != nil {
-				httpAccess.Response.Body = util.ToJson(err)
-			} else {
-				httpAccess.Response.Body = util.ToJson(reply)
-			}
+func newAccessLogger(opt Options) *accessLogger {
+	skip := opt.Skip
+	skip.Prefixes = append([]string{opt.IgnorePrefix}, skip.Prefixes...)
+	omitBody := opt.OmitBody
+	omitBody.ContentTypes = append(append([]string{}, opt.IgnoreContentTypes...), omitBody.ContentTypes...)
+	return &accessLogger{
+		opt:       opt,
+		skip:      skip.compile(),
+		omitBody:  omitBody.compile(),
+		requestID: opt.RequestID.withDefaults(),
+	}
+}
 
-			// if stdreq.Header.Get("Content-Type") == "" {
-			//	httpAccess.Response.Body = "[ignored]"
-			// } else if len(opt.IgnoreContentTypes) > 0 {
-			//	for _, tp := range opt.IgnoreContentTypes {
-			//		if strings.HasPrefix(stdreq.Header.Get("Content-Type"), tp) {
-			//			httpAccess.Response.Body = "[ignored]"
-			//		}
-			//	}
-			// }
-
-			if opt.RequestLogger != nil {
-				// xgo.GoDirect(opt.RequestLogger.Log, &httpAccess)
-				go opt.RequestLogger.Log(&httpAccess)
-			}
+// requestBody 脱敏后的请求体，不记录时为 [ignored]
+func (a *accessLogger) requestBody(req interface{}, header nethttp.Header, omit bool) string {
+	if omit || a.opt.HideRequestBodyFunc != nil && a.opt.HideRequestBodyFunc(header) {
+		return "[ignored]"
+	}
+	return a.opt.Redactor.JSON(util.ToJson(req))
+}
 
-			if opt.Debug {
-				opt.Logger.Log(log.LevelDebug, "【logging】", util.ToJson(httpAccess))
-			}
-			return
-		}
+// finish 记录响应和耗时
+func (a *accessLogger) finish(access *Access, start time.Time, req, reply interface{}, err error, omit bool, replyHeader transport.Header) {
+	opt := a.opt
+	// Stop timer
+	latency := time.Now().Sub(start)
+
+	access.ServerID = serverID
+	access.ServerPort = serverPort
+	access.Time = start
+	access.Request.Header = opt.Redactor.Header(access.Request.Header)
+	access.Request.URI = opt.Redactor.URL(access.Request.URI)
+	if opt.Redactor != nil && access.UserTrackFeature.AccessToken != "" {
+		strategy, _ := opt.Redactor.Key("access_token")
+		access.UserTrackFeature.AccessToken = redact.Mask(access.UserTrackFeature.AccessToken, strategy)
+	}
+	access.Response.Status = nethttp.StatusOK
+	access.Response.Header = opt.Redactor.Header(headerToMap(replyHeader))
+	access.Latency = latency.String()
+	access.LatencyNs = int64(latency)
+	if access.Protocol == ProtocolGRPC {
+		access.Request.Size = messageSize(req)
+		access.Response.Size = messageSize(reply)
+		access.Response.GRPCCode = grpcCode(err)
+	}
+	if err != nil {
+		// 状态码、错误原因等单独记录，与返回给客户端的一致
+		access.Error = newError(err)
+		access.Error.Message = opt.Redactor.String(access.Error.Message)
+		access.Error.Metadata = opt.Redactor.Header(access.Error.Metadata)
+		access.Response.Status = access.Error.Code
+	} else if omit {
+		access.Response.Body = "[ignored]"
+	} else {
+		access.Response.Body = opt.Redactor.JSON(util.ToJson(reply))
+	}
+}
+
+// log 写入 RequestLogger
+func (a *accessLogger) log(access *Access) {
+	opt := a.opt
+	if opt.RequestLogger != nil {
+		// xgo.GoDirect(opt.RequestLogger.Log, &httpAccess)
+		go opt.RequestLogger.Log(access)
+	}
+
+	if opt.Debug {
+		opt.Logger.Log(log.LevelDebug, "【logging】", util.ToJson(access))
+	}
+}
+
+// newHTTPAccess 由 HTTP 请求创建访问记录，不含请求体和请求ID
+func newHTTPAccess(stdreq *nethttp.Request, operation string) (*Access, requestInfo) {
+	info := requestInfo{
+		path:        stdreq.URL.Path,
+		operation:   operation,
+		method:      stdreq.Method,
+		contentType: stdreq.Header.Get("Content-Type"),
+	}
+	requestFeatures := prepareRequestFeatureMap(stdreq)
+	userTrack := usertrack.Parse(requestFeatures)
+	userTrack.AccessToken = stdreq.URL.Query().Get("access_token")
+	if userTrack.AccessToken == "" {
+		userTrack.AccessToken = stdreq.Header.Get("")
 	}
+	cookieSid, _ := stdreq.Cookie("SESSIONID")
+	if cookieSid == nil {
+		cookieSid, _ = stdreq.Cookie("com.zto.sessionId")
+	}
+	if cookieSid != nil {
+		userTrack.SessionID = cookieSid.Value
+	}
+
+	return &Access{
+		Protocol: ProtocolHTTP,
+		Request: Request{
+			Method:    stdreq.Method,
+			Path:      stdreq.URL.Path,
+			URI:       stdreq.URL.String(),
+			Operation: operation,
+			Header:    toMapString(stdreq.Header),
+		},
+		UserTrackFeature: userTrack,
+	}, info
 }
 
 func prepareRequestFeatureMap(r *nethttp.Request) map[string]interface{} {
@@ -252,15 +338,38 @@ func NewKafkaLogger(lHelper *log.Helper) *KafkaLogger {
 	return &KafkaLogger{lHelper}
 }
 
+// Print 按 klog.SaramaLevel 的规则区分级别，新代码请使用 klog.NewSaramaLogger
 func (l KafkaLogger) Print(v ...interface{}) {
-	l.Helper.Info(v...)
-	return
+	l.log(fmt.Sprint(v...))
 }
 func (l KafkaLogger) Printf(format string, v ...interface{}) {
-	l.Helper.Infof(format, v...)
-	return
+	l.log(fmt.Sprintf(format, v...))
 }
 func (l KafkaLogger) Println(v ...interface{}) {
-	l.Helper.Info(v...)
-	return
+	l.log(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func (l KafkaLogger) log(msg string) {
+	level, msg := klog.SaramaLevel(msg)
+	l.Helper.Log(kratosLevel(level), log.DefaultMessageKey, msg)
+}
+
+// kratosLevel 将 klog 的级别转换为 kratos 的级别，例：warning 为 log.LevelWarn，无效时为 log.LevelInfo
+func kratosLevel(level klog.Level) log.Level {
+	lvl, err := klog.ParseLevel(level)
+	if err != nil {
+		return log.LevelInfo
+	}
+	switch lvl {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return log.LevelDebug
+	case logrus.InfoLevel:
+		return log.LevelInfo
+	case logrus.WarnLevel:
+		return log.LevelWarn
+	case logrus.ErrorLevel:
+		return log.LevelError
+	default:
+		return log.LevelFatal
+	}
 }
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/darrenyjq/kratos-middleware/redact"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+func TestLoggerError(t *testing.T) {
+	accesses := make(chanRequestLogger, 1)
+	srv := http.NewServer(http.Middleware(Logger(Options{RequestLogger: accesses})))
+	srv.Route("/").GET("/user", func(ctx http.Context) error {
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			if tr, ok := transport.FromServerContext(ctx); ok {
+				tr.ReplyHeader().Set("X-Retry-After", "5")
+			}
+			return nil, errors.ServiceUnavailable("UNAVAILABLE", "try again").WithMetadata(map[string]string{"region": "sh"})
+		})
+		_, err := h(ctx, nil)
+		return err
+	})
+
+	r := httptest.NewRequest(nethttp.MethodGet, "/user", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+
+	access := <-accesses
+	if w.Code != nethttp.StatusServiceUnavailable || access.Response.Status != nethttp.StatusServiceUnavailable ||
+		access.Response.Body != "" {
+		t.Errorf("unexpected response %d: %+v", w.Code, access.Response)
+	}
+	if access.Error == nil || access.Error.Code != 503 || access.Error.Reason != "UNAVAILABLE" ||
+		access.Error.Message != "try again" || access.Error.Metadata["region"] != "sh" {
+		t.Errorf("unexpected error: %+v", access.Error)
+	}
+	if access.Response.Header["X-Retry-After"] != "5" || access.Response.Header["X-Request-Id"] != access.RequestID {
+		t.Errorf("expected reply headers, got %v", access.Response.Header)
+	}
+}
+
+func TestLoggerRedactAccessToken(t *testing.T) {
+	accesses := make(chanRequestLogger, 1)
+	srv := http.NewServer(http.Middleware(Logger(Options{RequestLogger: accesses, Redactor: redact.Default()})))
+	srv.Route("/").GET("/user", func(ctx http.Context) error {
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return map[string]string{"id": "1"}, nil
+		})
+		out, err := h(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return ctx.Result(nethttp.StatusOK, out)
+	})
+
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(nethttp.MethodGet, "/user?id=1&access_token=SECRET123", nil))
+	access := <-accesses
+	if strings.Contains(access.Request.URI, "SECRET123") || access.Request.URI != "/user?id=1&access_token=******" {
+		t.Errorf("expected the token to be redacted, got %s", access.Request.URI)
+	}
+	if strings.Contains(access.UserTrackFeature.AccessToken, "SECRET123") {
+		t.Errorf("expected the token to be redacted, got %s", access.UserTrackFeature.AccessToken)
+	}
+}
+
+type levelRecorder []log.Level
+
+func (r *levelRecorder) Log(level log.Level, keyvals ...interface{}) error {
+	*r = append(*r, level)
+	return nil
+}
+
+func TestKafkaLoggerLevel(t *testing.T) {
+	var levels levelRecorder
+	l := NewKafkaLogger(log.NewHelper(&levels))
+	l.Printf("[WARN] %s", "x")
+	l.Print("[ERROR] y")
+	l.Println("Connected to broker")
+	want := levelRecorder{log.LevelWarn, log.LevelError, log.LevelInfo}
+	if len(levels) != len(want) {
+		t.Fatalf("expected %v, got %v", want, levels)
+	}
+	for i := range want {
+		if levels[i] != want[i] {
+			t.Errorf("%d: expected %s, got %s", i, want[i], levels[i])
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Matcher 请求匹配规则，满足任一条件即为匹配
+type Matcher struct {
+	Prefixes     []string // 路径前缀 例：/dfs/public
+	Globs        []string // 路径通配符，语法同 path.Match 例：/api/*/health
+	Regexps      []string // 路径正则 例：^/v\d+/ping$
+	Operations   []string // kratos 的 Operation，完全匹配 例：/api.user.v1.User/Login
+	Methods      []string // HTTP 方法，不区分大小写 例：OPTIONS
+	ContentTypes []string // 请求 Content-Type 前缀，不区分大小写 例：multipart/form-data
+}
+
+// requestInfo 用于匹配的请求信息
+type requestInfo struct {
+	path        string
+	operation   string
+	method      string
+	contentType string
+}
+
+// matcher 编译后的 Matcher
+type matcher struct {
+	Matcher
+	regexps []*regexp.Regexp
+}
+
+// compile 编译正则，正则无效时 panic
+func (m Matcher) compile() *matcher {
+	c := &matcher{Matcher: m}
+	for _, v := range m.Regexps {
+		c.regexps = append(c.regexps, regexp.MustCompile(v))
+	}
+	return c
+}
+
+func (m *matcher) match(r requestInfo) bool {
+	for _, prefix := range m.Prefixes {
+		if prefix != "" && strings.HasPrefix(r.path, prefix) {
+			return true
+		}
+	}
+	for _, glob := range m.Globs {
+		if ok, _ := path.Match(glob, r.path); ok {
+			return true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(r.path) {
+			return true
+		}
+	}
+	for _, op := range m.Operations {
+		if op == r.operation {
+			return true
+		}
+	}
+	for _, method := range m.Methods {
+		if strings.EqualFold(method, r.method) {
+			return true
+		}
+	}
+	if r.contentType != "" {
+		contentType := strings.ToLower(r.contentType)
+		for _, tp := range m.ContentTypes {
+			if tp != "" && strings.HasPrefix(contentType, strings.ToLower(tp)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package logging
+
+import "testing"
+
+func TestMatcher(t *testing.T) {
+	m := Matcher{
+		Prefixes:     []string{"/dfs/public"},
+		Globs:        []string{"/api/*/health"},
+		Regexps:      []string{`^/v\d+/ping$`},
+		Operations:   []string{"/api.user.v1.User/Login"},
+		Methods:      []string{"OPTIONS"},
+		ContentTypes: []string{"multipart/form-data"},
+	}.compile()
+	tests := []struct {
+		info requestInfo
+		want bool
+	}{
+		{requestInfo{path: "/dfs/public/a.png", method: "GET"}, true},
+		{requestInfo{path: "/api/user/health", method: "GET"}, true},
+		{requestInfo{path: "/api/user/v1/health", method: "GET"}, false},
+		{requestInfo{path: "/v2/ping", method: "GET"}, true},
+		{requestInfo{path: "/login", operation: "/api.user.v1.User/Login", method: "POST"}, true},
+		{requestInfo{path: "/user", method: "options"}, true},
+		{requestInfo{path: "/upload", method: "POST", contentType: "Multipart/Form-Data; boundary=x"}, true},
+		{requestInfo{path: "/user", method: "POST", contentType: "application/json"}, false},
+	}
+	for _, tt := range tests {
+		if got := m.match(tt.info); got != tt.want {
+			t.Errorf("%+v: expected %v, got %v", tt.info, tt.want, got)
+		}
+	}
+}
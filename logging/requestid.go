@@ -0,0 +1,187 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darrenyjq/kratos-middleware/klog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IDGenerator 生成请求ID，ctx 为请求的 context
+type IDGenerator func(ctx context.Context) string
+
+// RequestIDOptions 请求ID的读取、生成和返回方式
+type RequestIDOptions struct {
+	Headers   []string    // 依次读取请求ID的请求头，默认 X-Request-Id、traceparent、trace.id
+	Generator IDGenerator // 请求头中没有时生成，默认 UUID()
+	Header    string      // 返回给客户端、传递给下游的请求头，默认 X-Request-Id，为 "-" 时不设置
+}
+
+// DefaultRequestIDHeaders 默认读取请求ID的请求头
+var DefaultRequestIDHeaders = []string{"X-Request-Id", "traceparent", "trace.id"}
+
+const defaultRequestIDHeader = "X-Request-Id"
+
+func (o RequestIDOptions) withDefaults() RequestIDOptions {
+	if len(o.Headers) == 0 {
+		o.Headers = DefaultRequestIDHeaders
+	}
+	if o.Generator == nil {
+		o.Generator = UUID()
+	}
+	if o.Header == "" {
+		o.Header = defaultRequestIDHeader
+	}
+	return o
+}
+
+// resolve 按顺序从请求头读取请求ID，都没有时生成
+func (o RequestIDOptions) resolve(ctx context.Context, header nethttp.Header) string {
+	for _, key := range o.Headers {
+		v := strings.TrimSpace(header.Get(key))
+		if strings.EqualFold(key, "traceparent") {
+			v = traceparentID(v)
+		}
+		if v != "" {
+			return v
+		}
+	}
+	return o.Generator(ctx)
+}
+
+// setHeader 设置返回给客户端或传递给下游的请求头
+func (o RequestIDOptions) setHeader(header interface{ Set(key, value string) }, requestID string) {
+	if o.Header != "-" && requestID != "" {
+		header.Set(o.Header, requestID)
+	}
+}
+
+// traceparentID W3C traceparent 中的 trace-id，格式无效时为空
+// 例：00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func traceparentID(v string) string {
+	parts := strings.Split(v, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[2]) != 16 {
+		return ""
+	}
+	traceID, err := trace.TraceIDFromHex(parts[1])
+	if err != nil {
+		return ""
+	}
+	return traceID.String()
+}
+
+// NewRequestIDContext 保存请求ID，Logger 中间件在处理请求前调用，klog.Logger.WithContext 会写入 request.id 字段
+func NewRequestIDContext(ctx context.Context, requestID string) context.Context {
+	return klog.NewRequestIDContext(ctx, requestID)
+}
+
+// RequestIDFromContext 取出服务端的请求ID
+// 例：requestID, _ := logging.RequestIDFromContext(ctx)
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	return klog.RequestIDFromContext(ctx)
+}
+
+// UUID 随机生成的 UUID v4 例：0f8e6d2c-3b1a-4c5d-9e7f-8a6b5c4d3e2f
+func UUID() IDGenerator {
+	return func(context.Context) string {
+		var b [16]byte
+		randomBytes(b[:])
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // variant 10
+		h := hex.EncodeToString(b[:])
+		return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+	}
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID 48位毫秒时间戳加80位随机数，26个字符，按时间排序 例：01ARZ3NDEKTSV4RRFFQ69G5FAV
+func ULID() IDGenerator {
+	return func(context.Context) string {
+		var b [16]byte
+		ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+		for i := 0; i < 6; i++ {
+			b[i] = byte(ms >> (40 - 8*i))
+		}
+		randomBytes(b[6:])
+		// 128位按5位一组编码，最高位补2个0
+		out := make([]byte, 26)
+		hi := uint64(b[0])<<56 | uint64(b[1])<<48 | uint64(b[2])<<40 | uint64(b[3])<<32 |
+			uint64(b[4])<<24 | uint64(b[5])<<16 | uint64(b[6])<<8 | uint64(b[7])
+		lo := uint64(b[8])<<56 | uint64(b[9])<<48 | uint64(b[10])<<40 | uint64(b[11])<<32 |
+			uint64(b[12])<<24 | uint64(b[13])<<16 | uint64(b[14])<<8 | uint64(b[15])
+		for i := 25; i >= 0; i-- {
+			out[i] = crockford[lo&0x1f]
+			lo = lo>>5 | hi<<59
+			hi >>= 5
+		}
+		return string(out)
+	}
+}
+
+// snowflakeEpoch 2020-01-01 00:00:00 UTC
+const snowflakeEpoch = 1577836800000
+
+// Snowflake 41位毫秒时间戳、10位节点号、12位序号组成的十进制ID，node 取值 0-1023，多实例部署时需各不相同
+// 同一毫秒内序号用完时等待下一毫秒
+func Snowflake(node int64) IDGenerator {
+	if node < 0 || node > 1023 {
+		panic(fmt.Sprintf("logging: snowflake node %d out of range [0, 1023]", node))
+	}
+	var (
+		mu   sync.Mutex
+		last int64
+		seq  int64
+	)
+	return func(context.Context) string {
+		mu.Lock()
+		defer mu.Unlock()
+		now := time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+		if now < last {
+			// 时钟回拨时沿用上次的时间戳
+			now = last
+		}
+		if now == last {
+			seq = (seq + 1) & 0xfff
+			if seq == 0 {
+				for now <= last {
+					time.Sleep(100 * time.Microsecond)
+					now = time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+				}
+			}
+		} else {
+			seq = 0
+		}
+		last = now
+		return strconv.FormatInt(now<<22|node<<12|seq, 10)
+	}
+}
+
+// TraceID 使用 ctx 中 span 的 trace id，需写在 tracing.Server 中间件之后
+// 没有有效的 span 时使用 fallback，为 nil 时使用 UUID()
+func TraceID(fallback IDGenerator) IDGenerator {
+	if fallback == nil {
+		fallback = UUID()
+	}
+	return func(ctx context.Context) string {
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+			return spanCtx.TraceID().String()
+		}
+		return fallback(ctx)
+	}
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("logging: read random bytes: %v", err))
+	}
+}
@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/darrenyjq/kratos-middleware/redact"
+
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestIDGenerators(t *testing.T) {
+	ctx := context.Background()
+	if id := UUID()(ctx); !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("invalid uuid: %s", id)
+	}
+	if id := ULID()(ctx); !regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`).MatchString(id) {
+		t.Errorf("invalid ulid: %s", id)
+	}
+
+	gen := Snowflake(3)
+	seen := make(map[string]bool)
+	var last int64
+	for i := 0; i < 10000; i++ {
+		id := gen(ctx)
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || n <= last || seen[id] || n>>12&0x3ff != 3 {
+			t.Fatalf("invalid snowflake %s after %d", id, last)
+		}
+		seen[id], last = true, n
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: trace.SpanID{1}})
+	if id := TraceID(nil)(trace.ContextWithSpanContext(ctx, spanCtx)); id != traceID.String() {
+		t.Errorf("expected trace id, got %s", id)
+	}
+	if id := TraceID(func(context.Context) string { return "fallback" })(ctx); id != "fallback" {
+		t.Errorf("expected fallback, got %s", id)
+	}
+}
+
+func TestSnowflakeNotRedacted(t *testing.T) {
+	accesses := make(chanRequestLogger, 1)
+	srv := http.NewServer(http.Middleware(Logger(Options{RequestLogger: accesses, Redactor: redact.Default()})))
+	srv.Route("/").GET("/id", func(ctx http.Context) error {
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		_, err := h(ctx, nil)
+		return err
+	})
+	r := httptest.NewRequest(nethttp.MethodGet, "/id", nil)
+	r.Header.Set("X-Request-Id", "902345678901234567")
+	srv.ServeHTTP(httptest.NewRecorder(), r)
+	access := <-accesses
+	if access.Request.Header["X-Request-Id"] != "902345678901234567" || access.Response.Header["X-Request-Id"] != "902345678901234567" {
+		t.Errorf("expected the request id unchanged, got %s %s", access.Request.Header["X-Request-Id"], access.Response.Header["X-Request-Id"])
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	serverAccesses := make(chanRequestLogger, 1)
+	srv := http.NewServer(http.Middleware(Logger(Options{RequestLogger: serverAccesses})))
+	srv.Route("/").GET("/id", func(ctx http.Context) error {
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			requestID, _ := RequestIDFromContext(ctx)
+			return map[string]string{"id": requestID}, nil
+		})
+		out, err := h(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return ctx.Result(nethttp.StatusOK, out)
+	})
+
+	// 按顺序读取请求头，traceparent 取其中的 trace-id
+	r := httptest.NewRequest(nethttp.MethodGet, "/id", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set("trace.id", "legacy")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	access := <-serverAccesses
+	if access.RequestID != "4bf92f3577b34da6a3ce929d0e0e4736" || w.Header().Get("X-Request-Id") != access.RequestID ||
+		access.UserTrackFeature.HttpRequestID != access.RequestID || access.Response.Body != `{"id":"4bf92f3577b34da6a3ce929d0e0e4736"}` {
+		t.Fatalf("unexpected request id %q, response header %q, body %s", access.RequestID, w.Header().Get("X-Request-Id"), access.Response.Body)
+	}
+
+	// 客户端生成的请求ID传递给下游，作为下游的请求ID
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	clientAccesses := make(chanRequestLogger, 1)
+	client, err := http.NewClient(context.Background(),
+		http.WithEndpoint(ts.Listener.Addr().String()),
+		http.WithMiddleware(Client(Options{RequestLogger: clientAccesses, RequestID: RequestIDOptions{Generator: ULID()}})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var out map[string]string
+	if err = client.Invoke(NewRequestIDContext(context.Background(), "parent"), nethttp.MethodGet, "/id", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	clientAccess, serverAccess := <-clientAccesses, <-serverAccesses
+	if len(clientAccess.RequestID) != 26 || clientAccess.ParentRequestID != "parent" ||
+		serverAccess.RequestID != clientAccess.RequestID || out["id"] != clientAccess.RequestID {
+		t.Fatalf("expected the same request id, got client %q, server %q, handler %q", clientAccess.RequestID, serverAccess.RequestID, out["id"])
+	}
+}
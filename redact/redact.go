@@ -0,0 +1,291 @@
+package redact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Strategy 脱敏方式
+type Strategy int
+
+const (
+	Full    Strategy = iota // 全部替换为 ******
+	Partial                 // 保留首尾各四分之一，中间替换为 *
+	Hash                    // 替换为 sha256 摘要的前16位，便于关联同一个值
+)
+
+const fullMask = "******"
+
+// ValueRule 按值匹配的脱敏规则
+type ValueRule struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Strategy Strategy
+	Validate func(string) bool // 进一步校验匹配的内容，为 nil 时全部脱敏
+}
+
+// DefaultKeys 默认脱敏的字段名，不区分大小写
+var DefaultKeys = []string{
+	"password", "passwd", "pwd", "secret",
+	"token", "access_token", "refresh_token", "x-access-token",
+	"authorization", "cookie", "set-cookie",
+}
+
+// DefaultValueRules 默认按值脱敏的规则：身份证号、手机号、邮箱
+var DefaultValueRules = []ValueRule{
+	{Name: "id_card", Pattern: idCardRegexp, Strategy: Partial, Validate: validIDCard},
+	{Name: "phone", Pattern: regexp.MustCompile(`\b1[3-9]\d{9}\b`), Strategy: Partial},
+	{Name: "email", Pattern: regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`), Strategy: Partial},
+}
+
+// idCardRegexp 18位身份证号：地区码、出生日期、顺序码和校验码
+var idCardRegexp = regexp.MustCompile(`\b[1-9]\d{5}(18|19|20)\d{2}(0[1-9]|1[0-2])(0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`)
+
+// validIDCard 按 GB 11643 校验最后一位，避免将雪花算法等生成的18位数字当作身份证号
+func validIDCard(s string) bool {
+	weights := [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(s[i]-'0') * w
+	}
+	return string("10X98765432"[sum%11]) == strings.ToUpper(s[17:])
+}
+
+// Option is redactor option.
+type Option func(*Redactor)
+
+// WithKeys 按字段名脱敏，不区分大小写
+func WithKeys(strategy Strategy, keys ...string) Option {
+	return func(r *Redactor) {
+		for _, k := range keys {
+			r.keys[strings.ToLower(k)] = strategy
+		}
+	}
+}
+
+// WithValueRule 按正则匹配值脱敏
+func WithValueRule(name, pattern string, strategy Strategy) Option {
+	return func(r *Redactor) {
+		r.values = append(r.values, ValueRule{Name: name, Pattern: regexp.MustCompile(pattern), Strategy: strategy})
+	}
+}
+
+// Redactor 脱敏引擎，供 klog 和 logging 共用
+type Redactor struct {
+	keys   map[string]Strategy
+	values []ValueRule
+}
+
+// New 创建不含任何规则的脱敏引擎
+func New(opts ...Option) *Redactor {
+	r := &Redactor{keys: make(map[string]Strategy)}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// Default 创建包含默认规则的脱敏引擎，opts 可追加或覆盖规则
+func Default(opts ...Option) *Redactor {
+	r := New(WithKeys(Full, DefaultKeys...))
+	r.values = append(r.values, DefaultValueRules...)
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// Mask 按脱敏方式处理字符串
+func Mask(s string, strategy Strategy) string {
+	switch strategy {
+	case Partial:
+		rs := []rune(s)
+		keep := len(rs) / 4
+		if keep == 0 {
+			return strings.Repeat("*", len(rs))
+		}
+		return string(rs[:keep]) + strings.Repeat("*", len(rs)-2*keep) + string(rs[len(rs)-keep:])
+	case Hash:
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:])[:16]
+	default:
+		return fullMask
+	}
+}
+
+// Key 字段名是否需要脱敏
+func (r *Redactor) Key(key string) (Strategy, bool) {
+	if r == nil {
+		return Full, false
+	}
+	strategy, ok := r.keys[strings.ToLower(key)]
+	return strategy, ok
+}
+
+// String 按值规则脱敏字符串中匹配的部分
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, rule := range r.values {
+		rule := rule
+		s = rule.Pattern.ReplaceAllStringFunc(s, func(m string) string {
+			if rule.Validate != nil && !rule.Validate(m) {
+				return m
+			}
+			return Mask(m, rule.Strategy)
+		})
+	}
+	return s
+}
+
+// Field 字段名匹配时整体脱敏，否则按值规则脱敏字符串
+func (r *Redactor) Field(key string, value interface{}) interface{} {
+	if r == nil {
+		return value
+	}
+	if strategy, ok := r.Key(key); ok {
+		return Mask(fmt.Sprint(value), strategy)
+	}
+	if s, ok := value.(string); ok {
+		return r.String(s)
+	}
+	return value
+}
+
+// Header 脱敏请求头或响应头，返回新的 map
+func (r *Redactor) Header(header map[string]string) map[string]string {
+	if r == nil {
+		return header
+	}
+	ret := make(map[string]string, len(header))
+	for k, v := range header {
+		ret[k] = r.Field(k, v).(string)
+	}
+	return ret
+}
+
+// JSON 脱敏 JSON 字符串中匹配的字段和值，无法解析时按 "字段": 值 和值规则处理
+func (r *Redactor) JSON(body string) string {
+	if r == nil || body == "" {
+		return body
+	}
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil || decoder.More() {
+		return r.Raw(body)
+	}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(r.walk(data)); err != nil {
+		return r.String(body)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Raw 脱敏原始内容，不重新编码：按 "字段": 值 和值规则处理，其余内容、空白和字段顺序保持不变
+// 用于需要记录实际收发内容的场景，例：logging.CaptureBody
+func (r *Redactor) Raw(body string) string {
+	if r == nil || body == "" {
+		return body
+	}
+	return r.String(r.jsonPairs(body))
+}
+
+// jsonPairRegexp 不完整的 JSON 中的 "字段": 值，值可以是未结束的字符串
+var jsonPairRegexp = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)("(?:[^"\\]|\\.)*"?|[^\s,{}\[\]"]+)`)
+
+// jsonPairs 按字段名脱敏无法解析的 JSON，例：截断后的请求体
+func (r *Redactor) jsonPairs(body string) string {
+	return jsonPairRegexp.ReplaceAllStringFunc(body, func(m string) string {
+		sub := jsonPairRegexp.FindStringSubmatch(m)
+		strategy, ok := r.Key(sub[1])
+		if !ok {
+			return m
+		}
+		value := strings.TrimSuffix(strings.TrimPrefix(sub[3], `"`), `"`)
+		return `"` + sub[1] + `"` + sub[2] + `"` + Mask(value, strategy) + `"`
+	})
+}
+
+// Form 脱敏 application/x-www-form-urlencoded 格式的内容，保持原有顺序，不是表单时按值规则处理
+// 例：username=bob&password=hunter2 脱敏为 username=bob&password=******
+func (r *Redactor) Form(body string) string {
+	if r == nil || body == "" {
+		return body
+	}
+	if _, err := url.ParseQuery(body); err != nil {
+		return r.String(body)
+	}
+	pairs := strings.Split(body, "&")
+	for i, pair := range pairs {
+		j := strings.IndexByte(pair, '=')
+		if j < 0 {
+			continue
+		}
+		rawKey, value := pair[:j], pair[j+1:]
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		unescaped, err := url.QueryUnescape(value)
+		if err != nil {
+			unescaped = value
+		}
+		if strategy, ok := r.Key(key); ok {
+			value = Mask(unescaped, strategy)
+		} else if redacted := r.String(unescaped); redacted != unescaped {
+			value = redacted
+		}
+		pairs[i] = rawKey + "=" + value
+	}
+	return strings.Join(pairs, "&")
+}
+
+// URL 按 Form 的规则脱敏 URL 中的查询参数 例：/user?access_token=abc 脱敏为 /user?access_token=******
+func (r *Redactor) URL(uri string) string {
+	i := strings.IndexByte(uri, '?')
+	if r == nil || i < 0 {
+		return uri
+	}
+	return uri[:i+1] + r.Form(uri[i+1:])
+}
+
+func (r *Redactor) walk(data interface{}) interface{} {
+	switch val := data.(type) {
+	case map[string]interface{}:
+		for k, v := range val {
+			if strategy, ok := r.Key(k); ok {
+				if _, isString := v.(string); !isString {
+					v = compact(v)
+				}
+				val[k] = Mask(fmt.Sprint(v), strategy)
+				continue
+			}
+			val[k] = r.walk(v)
+		}
+		return val
+	case []interface{}:
+		for i, v := range val {
+			val[i] = r.walk(v)
+		}
+		return val
+	case string:
+		return r.String(val)
+	default:
+		return data
+	}
+}
+
+func compact(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
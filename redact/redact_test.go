@@ -0,0 +1,62 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	r := Default(WithKeys(Hash, "user_id"))
+	got := r.JSON(`{"password":"123456","user_id":42,"profile":{"phone":"13812345678","email":"ha666@example.com"},"list":[{"Access_Token":"abc"}]}`)
+	for _, s := range []string{"123456", "13812345678", "ha666@example.com", `"abc"`, "42"} {
+		if strings.Contains(got, s) {
+			t.Errorf("expected %s to be redacted, got %s", s, got)
+		}
+	}
+	if !strings.Contains(got, `"phone":"13*******78"`) || !strings.Contains(got, `"password":"******"`) ||
+		!strings.Contains(got, `"user_id":"sha256:`) {
+		t.Errorf("unexpected result %s", got)
+	}
+	if got = r.JSON("mobile=13812345678"); got != "mobile=13*******78" {
+		t.Errorf("unexpected result %s", got)
+	}
+}
+
+func TestIDCard(t *testing.T) {
+	r := Default()
+	if got := r.String("id=11010519491231002X"); got != "id=1101**********002X" {
+		t.Errorf("expected the id card to be redacted, got %s", got)
+	}
+	// 雪花算法生成的请求ID、校验码错误的号码不脱敏
+	for _, s := range []string{"902345678901234567", "110105194912310021", "883920118233972736"} {
+		if got := r.String(s); got != s {
+			t.Errorf("expected %s unchanged, got %s", s, got)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	got := Default().Header(map[string]string{"Authorization": "Bearer abc", "User-Agent": "curl"})
+	if got["Authorization"] != fullMask || got["User-Agent"] != "curl" {
+		t.Errorf("unexpected result %v", got)
+	}
+}
+
+func TestForm(t *testing.T) {
+	r := Default()
+	got := r.Form("username=bob&password=hunter2&access_token=abc&email=ha666%40example.com&flag")
+	if got != "username=bob&password=******&access_token=******&email=ha66*********.com&flag" {
+		t.Errorf("unexpected result %s", got)
+	}
+	if got = r.URL("/user?id=1&access_token=SECRET123"); got != "/user?id=1&access_token=******" {
+		t.Errorf("unexpected result %s", got)
+	}
+	// 原始内容的空白、字段顺序和数字格式不变
+	if got = r.Raw(`{ "b": 1.50, "password" : "hunter2", "a": [1, 2] }`); got != `{ "b": 1.50, "password" : "******", "a": [1, 2] }` {
+		t.Errorf("unexpected result %s", got)
+	}
+	// 截断的 JSON 按字段名脱敏
+	if got = r.JSON(`{"user":"bob","password":"hunter2","token":"abcd`); got != `{"user":"bob","password":"******","token":"******"` {
+		t.Errorf("unexpected result %s", got)
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"net/url"
 	"strings"
 
-	"kratos-middleware/util"
+	"github.com/darrenyjq/kratos-middleware/util"
 
 	"github.com/go-kratos/kratos/v2/metadata"
 	"github.com/go-kratos/kratos/v2/transport"
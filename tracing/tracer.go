@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	"kratos-middleware/util"
+	"github.com/darrenyjq/kratos-middleware/util"
 
 	"github.com/go-kratos/kratos/v2/errors"
 	"go.opentelemetry.io/otel"